@@ -12,7 +12,7 @@ import (
 func main() {
 	ctx := context.Background()
 	widgetStore := memorystore.NewStore[widget.DatabaseWidget, widget.WidgetParams](nil)
-	widgetService := widget.NewService(widgetStore)
+	widgetService := widget.NewService(widgetStore, pagination.DefaultPolicy)
 
 	_, err := widgetService.Create(
 		ctx,
@@ -30,24 +30,24 @@ func main() {
 		)
 	}
 
-	list, err := widgetService.List(
-		ctx,
-		widget.WidgetParams{Pagination: pagination.New(
-			pagination.Params{Limit: 6},
-		)},
-	)
+	first, err := pagination.New(pagination.Params{Limit: 6})
+	if err != nil {
+		panic(err)
+	}
+
+	list, err := widgetService.List(ctx, widget.WidgetParams{Pagination: first})
 	if err != nil {
 		fmt.Println(err)
 	}
 
 	fmt.Printf("Response: %#v\n", list)
 
-	after, err := widgetService.List(
-		ctx,
-		widget.WidgetParams{Pagination: pagination.New(
-			pagination.Params{After: list.After},
-		)},
-	)
+	next, err := pagination.New(pagination.Params{After: list.After})
+	if err != nil {
+		panic(err)
+	}
+
+	after, err := widgetService.List(ctx, widget.WidgetParams{Pagination: next})
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -2,15 +2,19 @@ package main_test
 
 import (
 	"context"
+	"fmt"
 	"pckilgore/app/pointers"
+	"pckilgore/app/store"
 	"pckilgore/app/store/gormstore"
 	"pckilgore/app/store/memorystore"
+	"pckilgore/app/store/pagination"
 	"pckilgore/app/widget"
 	"strings"
 	"testing"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"os"
 )
 
@@ -27,7 +31,7 @@ func BenchmarkMemorySqliteStore(b *testing.B) {
 
 	widgetStore := gormstore.NewStore[widget.DatabaseWidget, widget.WidgetParams](db)
 
-	widgetService := widget.NewService(widgetStore)
+	widgetService := widget.NewService(widgetStore, pagination.DefaultPolicy)
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -61,7 +65,7 @@ func BenchmarkFileSqliteStore(b *testing.B) {
 	if err != nil {
 		b.Fatalf("couldn't initialize store")
 	}
-	widgetService := widget.NewService(widgetStore)
+	widgetService := widget.NewService(widgetStore, pagination.DefaultPolicy)
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -92,7 +96,7 @@ func BenchmarkFileSqliteStore(b *testing.B) {
 func BenchmarkMemoryStore(b *testing.B) {
 	ctx := context.Background()
 	widgetStore := memorystore.NewStore[widget.DatabaseWidget, widget.WidgetParams]()
-	widgetService := widget.NewService(widgetStore)
+	widgetService := widget.NewService(widgetStore, pagination.DefaultPolicy)
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -113,6 +117,59 @@ func BenchmarkMemoryStore(b *testing.B) {
 	}
 }
 
+var LIST_SEED_COUNT = 10000
+
+// benchmarkSqliteList lists the first page of a large table with each count
+// mode, since counting dominates listing on large tables.
+func benchmarkSqliteList(b *testing.B, mode store.CountMode) {
+	ctx := context.Background()
+	db, err := gorm.Open(
+		sqlite.Open(fmt.Sprintf("file:list%d?mode=memory&cache=shared", mode)),
+		&gorm.Config{Logger: logger.Discard},
+	)
+	if err != nil {
+		b.Fatalf("couldn't open db connection")
+	}
+	db.AutoMigrate(&widget.DatabaseWidget{})
+
+	widgetStore := gormstore.NewStore[widget.DatabaseWidget, widget.WidgetParams](db)
+	widgetService := widget.NewService(widgetStore, pagination.DefaultPolicy)
+
+	seed := make([]widget.WidgetTemplate, LIST_SEED_COUNT)
+	for i := range seed {
+		seed[i] = widget.WidgetTemplate{Name: pointers.Make("My Widget")}
+	}
+	if _, err := widgetService.CreateMany(ctx, seed); err != nil {
+		b.Fatalf("couldn't seed widgets")
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		list, err := widgetService.List(ctx, widget.WidgetParams{
+			Pagination: pagination.MustNew(pagination.Params{Limit: 50, Count: pointers.Make(mode)}),
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		if len(list.Items) != 50 || list.After == nil {
+			b.Fatalf("list failed")
+		}
+	}
+}
+
+func BenchmarkSqliteListCountExact(b *testing.B) {
+	benchmarkSqliteList(b, store.CountExact)
+}
+
+func BenchmarkSqliteListCountNone(b *testing.B) {
+	benchmarkSqliteList(b, store.CountNone)
+}
+
+func BenchmarkSqliteListCountEstimate(b *testing.B) {
+	benchmarkSqliteList(b, store.CountEstimate)
+}
+
 var result string
 
 func BenchmarkSmallConcat(b *testing.B) {
@@ -5,6 +5,7 @@ import (
 	"pckilgore/app/pointers"
 
 	"pckilgore/app/store/gormstore"
+	"pckilgore/app/store/memorystore"
 	"pckilgore/app/store/pagination"
 
 	"github.com/google/uuid"
@@ -36,6 +37,12 @@ func (d DatabaseNode) GetParentID() *string {
 	return d.ParentID
 }
 
+// WithParentID implements [store.Movable].
+func (d DatabaseNode) WithParentID(parentID *string) DatabaseNode {
+	d.ParentID = parentID
+	return d
+}
+
 func (DatabaseNode) GetParentIDField() string {
 	return "parent_id"
 }
@@ -44,6 +51,12 @@ func (DatabaseNode) TableName() string {
 	return "nodes"
 }
 
+// MemoryIndexes implements [memorystore.Indexed], so lists sorted by name are
+// served from an index.
+func (DatabaseNode) MemoryIndexes() []string {
+	return []string{"Name"}
+}
+
 func (w NodeParams) GormFilter(db *gorm.DB) *gorm.DB {
 	return db.Scopes(
 		gormstore.ColumnInIDs("id", w.IDs),
@@ -51,6 +64,24 @@ func (w NodeParams) GormFilter(db *gorm.DB) *gorm.DB {
 	)
 }
 
+// IndexFilter implements [memorystore.IndexFilterer].
+func (w NodeParams) IndexFilter() memorystore.IndexFilter {
+	if w.ParentIDs == nil {
+		return nil
+	}
+
+	var parentIDs []any
+	for _, id := range *w.ParentIDs {
+		if string(id) == gormstore.Null {
+			parentIDs = append(parentIDs, nil)
+		} else {
+			parentIDs = append(parentIDs, id)
+		}
+	}
+
+	return memorystore.IndexFilter{"ParentID": parentIDs}
+}
+
 type filterIn[T any] func(cur T) bool
 
 func filterMany[T any](items []T, filters ...filterIn[T]) []T {
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CursorCodec converts a [Cursor] to and from a token. Decode must fail with
+// [ErrInvalidCursor] for any token it did not encode.
+type CursorCodec interface {
+	Encode(c Cursor) (string, error)
+	Decode(token string) (*Cursor, error)
+}
+
+// CursorKey is a secret used to sign, and optionally encrypt, cursor tokens.
+type CursorKey struct {
+	// ID is written into tokens, so that the key which signed a token can be
+	// found after rotation. It must be unique within a codec, and is not secret.
+	ID string
+
+	// Secret must be at least 32 random bytes.
+	Secret []byte
+}
+
+type SignedCursorCodecOptions struct {
+	// Keys to sign and verify tokens with. The first key signs new tokens; the
+	// rest only verify tokens signed before they were rotated out.
+	Keys []CursorKey
+
+	// Encrypt hides cursor values from clients with AES-GCM. Tokens are signed
+	// either way, and signed-only tokens are still accepted.
+	Encrypt bool
+}
+
+const (
+	codecVersion  byte = 1
+	flagEncrypted byte = 1 << 0
+	macSize            = sha256.Size
+	minSecretSize      = 32
+)
+
+type derivedKey struct {
+	id   string
+	mac  []byte
+	aead cipher.AEAD
+}
+
+// SignedCursorCodec produces opaque, tamper-proof cursor tokens with an
+// HMAC-SHA256 signature, and optionally AES-256-GCM encryption.
+type SignedCursorCodec struct {
+	keys    []derivedKey
+	encrypt bool
+}
+
+func NewSignedCursorCodec(o SignedCursorCodecOptions) (*SignedCursorCodec, error) {
+	if len(o.Keys) == 0 {
+		return nil, errors.New("at least one cursor key is required")
+	}
+
+	codec := &SignedCursorCodec{encrypt: o.Encrypt}
+	seen := make(map[string]bool, len(o.Keys))
+	for _, key := range o.Keys {
+		if len(key.ID) > 255 {
+			return nil, errors.Errorf("cursor key id %q is too long", key.ID)
+		} else if seen[key.ID] {
+			return nil, errors.Errorf("cursor key id %q is not unique", key.ID)
+		} else if len(key.Secret) < minSecretSize {
+			return nil, errors.Errorf("cursor key %q must be at least %d bytes", key.ID, minSecretSize)
+		}
+		seen[key.ID] = true
+
+		// Never use the same key for two purposes.
+		block, err := aes.NewCipher(derive(key.Secret, "cursor encryption"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create cursor cipher")
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create cursor cipher")
+		}
+
+		codec.keys = append(codec.keys, derivedKey{
+			id:   key.ID,
+			mac:  derive(key.Secret, "cursor signing"),
+			aead: aead,
+		})
+	}
+
+	return codec, nil
+}
+
+// Encode signs, and optionally encrypts, a cursor with the current key.
+//
+// Tokens are laid out as: version, flags, key ID length, key ID, body, MAC;
+// where body is the cursor, or a nonce and the sealed cursor when encrypted.
+func (s *SignedCursorCodec) Encode(c Cursor) (string, error) {
+	key := s.keys[0]
+
+	header := []byte{codecVersion, 0, byte(len(key.id))}
+	header = append(header, key.id...)
+
+	body := []byte(c.body())
+	if s.encrypt {
+		header[1] |= flagEncrypted
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", errors.Wrap(err, "failed to generate cursor nonce")
+		}
+		body = key.aead.Seal(nonce, nonce, body, header)
+	}
+
+	signed := append(header, body...)
+	signed = append(signed, sign(key.mac, signed)...)
+
+	return prefix + base64.RawURLEncoding.EncodeToString(signed), nil
+}
+
+// Decode verifies, and if needed decrypts, a token from any known key.
+func (s *SignedCursorCodec) Decode(token string) (*Cursor, error) {
+	sansPrefix, found := strings.CutPrefix(token, prefix)
+	if !found {
+		return nil, errors.Wrap(ErrInvalidCursor, "missing prefix")
+	}
+
+	signed, err := base64.RawURLEncoding.DecodeString(sansPrefix)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+
+	if len(signed) < 3+macSize || signed[0] != codecVersion {
+		return nil, errors.Wrap(ErrInvalidCursor, "unknown format")
+	}
+	headerSize := 3 + int(signed[2])
+	if len(signed) < headerSize+macSize {
+		return nil, errors.Wrap(ErrInvalidCursor, "unknown format")
+	}
+
+	header := signed[:headerSize]
+	body := signed[headerSize : len(signed)-macSize]
+	mac := signed[len(signed)-macSize:]
+
+	key, found := s.key(string(header[3:]))
+	if !found {
+		return nil, errors.Wrap(ErrInvalidCursor, "unknown key")
+	}
+	if !hmac.Equal(mac, sign(key.mac, signed[:len(signed)-macSize])) {
+		return nil, errors.Wrap(ErrInvalidCursor, "bad signature")
+	}
+
+	if header[1]&flagEncrypted != 0 {
+		nonceSize := key.aead.NonceSize()
+		if len(body) < nonceSize {
+			return nil, errors.Wrap(ErrInvalidCursor, "unknown format")
+		}
+		body, err = key.aead.Open(nil, body[:nonceSize], body[nonceSize:], header)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidCursor, "failed to decrypt")
+		}
+	}
+
+	return parseBody(string(body))
+}
+
+func (s *SignedCursorCodec) key(id string) (derivedKey, bool) {
+	for _, key := range s.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+
+	return derivedKey{}, false
+}
+
+func derive(secret []byte, purpose string) []byte {
+	return sign(secret, []byte(purpose))
+}
+
+func sign(key []byte, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	return h.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package store_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"pckilgore/app/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedCursorCodec(t *testing.T) {
+	t.Parallel()
+
+	oldKey := store.CursorKey{ID: "2023-01", Secret: []byte(strings.Repeat("o", 32))}
+	newKey := store.CursorKey{ID: "2023-02", Secret: []byte(strings.Repeat("n", 32))}
+
+	cursor, err := store.NewKeysetCursor("some-internal-id", "some name", 42)
+	require.Nil(t, err)
+
+	for _, encrypt := range []bool{false, true} {
+		old, err := store.NewSignedCursorCodec(store.SignedCursorCodecOptions{
+			Keys:    []store.CursorKey{oldKey},
+			Encrypt: encrypt,
+		})
+		require.Nil(t, err)
+
+		rotated, err := store.NewSignedCursorCodec(store.SignedCursorCodecOptions{
+			Keys:    []store.CursorKey{newKey, oldKey},
+			Encrypt: encrypt,
+		})
+		require.Nil(t, err)
+
+		t.Run("round trip", func(t *testing.T) {
+			token, err := rotated.Encode(cursor)
+			require.Nil(t, err)
+
+			parsed, err := store.Parse(token, rotated)
+			require.Nil(t, err)
+			require.Equal(t, cursor, *parsed)
+
+			_, err = store.Parse(token)
+			require.ErrorIs(t, err, store.ErrInvalidCursor, "signed tokens are not plain tokens")
+
+			_, err = store.Parse(token, rotated, old)
+			require.ErrorIs(t, err, store.ErrInvalidInput, "only one codec can decode a token")
+		})
+
+		t.Run("rotation", func(t *testing.T) {
+			token, err := old.Encode(cursor)
+			require.Nil(t, err)
+
+			parsed, err := rotated.Decode(token)
+			require.Nil(t, err, "tokens signed by a rotated key should still verify")
+			require.Equal(t, cursor, *parsed)
+
+			token, err = rotated.Encode(cursor)
+			require.Nil(t, err)
+
+			_, err = old.Decode(token)
+			require.ErrorIs(t, err, store.ErrInvalidCursor, "tokens signed by an unknown key should not verify")
+		})
+
+		t.Run("tampering", func(t *testing.T) {
+			token, err := rotated.Encode(cursor)
+			require.Nil(t, err)
+
+			raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, "cursor_"))
+			require.Nil(t, err)
+			for i := range raw {
+				tampered := append([]byte{}, raw...)
+				tampered[i] ^= 1
+				_, err := rotated.Decode("cursor_" + base64.RawURLEncoding.EncodeToString(tampered))
+				require.ErrorIsf(t, err, store.ErrInvalidCursor, "flipping byte %d should invalidate the token", i)
+				require.ErrorIs(t, err, store.ErrInvalidPagination)
+			}
+
+			_, err = rotated.Decode(cursor.Token())
+			require.ErrorIs(t, err, store.ErrInvalidCursor, "plain tokens should not verify")
+
+			_, err = rotated.Decode("cursor_")
+			require.ErrorIs(t, err, store.ErrInvalidCursor)
+		})
+	}
+
+	t.Run("encryption hides values", func(t *testing.T) {
+		codec, err := store.NewSignedCursorCodec(store.SignedCursorCodecOptions{
+			Keys:    []store.CursorKey{newKey},
+			Encrypt: true,
+		})
+		require.Nil(t, err)
+
+		token, err := codec.Encode(cursor)
+		require.Nil(t, err)
+
+		raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, "cursor_"))
+		require.Nil(t, err)
+		require.NotContains(t, string(raw), base64.URLEncoding.EncodeToString([]byte(cursor.Value())))
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		_, err := store.NewSignedCursorCodec(store.SignedCursorCodecOptions{})
+		require.NotNil(t, err, "a key is required")
+
+		_, err = store.NewSignedCursorCodec(store.SignedCursorCodecOptions{
+			Keys: []store.CursorKey{{ID: "short", Secret: []byte("too short")}},
+		})
+		require.NotNil(t, err, "short secrets should be rejected")
+
+		_, err = store.NewSignedCursorCodec(store.SignedCursorCodecOptions{
+			Keys: []store.CursorKey{oldKey, oldKey},
+		})
+		require.NotNil(t, err, "duplicate key IDs should be rejected")
+	})
+}
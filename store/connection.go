@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ConnectionArgs are the pagination arguments of the GraphQL Cursor
+// Connections spec. Use First with After to page forwards, or Last with Before
+// to page backwards. Last without Before pages backwards from the end.
+type ConnectionArgs struct {
+	First  *int
+	After  *Cursor
+	Last   *int
+	Before *Cursor
+}
+
+// Edge is a single item in a [Connection], and a cursor pointing at it.
+type Edge[Model any] struct {
+	Node   Model
+	Cursor Cursor
+}
+
+type PageInfo struct {
+	HasNextPage     bool
+	HasPreviousPage bool
+
+	// StartCursor and EndCursor point at the first and last edges, or are nil
+	// when there are none.
+	StartCursor *Cursor
+	EndCursor   *Cursor
+}
+
+// Connection is a page of results in the shape of the GraphQL Cursor
+// Connections spec.
+type Connection[Model any] struct {
+	Edges    []Edge[Model]
+	PageInfo PageInfo
+
+	// TotalCount is the [ListResponse] Count.
+	TotalCount int
+}
+
+// ListConnection lists a page of a [Connection] from any [Lister]. Build turns
+// the connection arguments into the lister's parameters, and is where filters
+// and sorts are applied; a limit of zero requests the default page size.
+//
+// Last without Before lists the final edges, by listing before an
+// [EndCursor]. Mixing directions, with First and Before or Last and After, is
+// not supported, and errors with [ErrInvalidPagination].
+func ListConnection[Model Storable, Params Parameterized](
+	ctx context.Context,
+	l Lister[Model, Params],
+	args ConnectionArgs,
+	build func(limit int, after *Cursor, before *Cursor) Params,
+) (Connection[Model], error) {
+	if args.First != nil && args.Last != nil {
+		return Connection[Model]{}, errors.Wrap(ErrInvalidPagination, "only one of first or last can be set")
+	} else if args.First != nil && *args.First < 0 {
+		return Connection[Model]{}, errors.Wrap(ErrInvalidPagination, "first cannot be negative")
+	} else if args.Last != nil && *args.Last < 0 {
+		return Connection[Model]{}, errors.Wrap(ErrInvalidPagination, "last cannot be negative")
+	} else if args.First != nil && args.Before != nil {
+		return Connection[Model]{}, errors.Wrap(ErrInvalidPagination, "first cannot page before a cursor")
+	} else if args.Last != nil && args.After != nil {
+		return Connection[Model]{}, errors.Wrap(ErrInvalidPagination, "last cannot page after a cursor")
+	}
+
+	limit := 0
+	if args.First != nil {
+		limit = *args.First
+	} else if args.Last != nil {
+		limit = *args.Last
+	}
+
+	// A limit of zero is the default page size, so there's nothing to list.
+	if limit == 0 && (args.First != nil || args.Last != nil) {
+		return Connection[Model]{}, nil
+	}
+
+	before := args.Before
+	if args.Last != nil && before == nil {
+		end := EndCursor()
+		before = &end
+	}
+
+	list, err := l.List(ctx, build(limit, args.After, before))
+	if err != nil {
+		return Connection[Model]{}, err
+	}
+
+	edges := make([]Edge[Model], len(list.Items))
+	for i, item := range list.Items {
+		edges[i].Node = item
+		if len(list.Cursors) == len(list.Items) {
+			edges[i].Cursor = list.Cursors[i]
+		} else {
+			edges[i].Cursor = NewCursor(item.GetID())
+		}
+	}
+
+	info := PageInfo{
+		HasNextPage:     list.After != nil,
+		HasPreviousPage: list.Before != nil,
+	}
+	if len(edges) > 0 {
+		info.StartCursor = &edges[0].Cursor
+		info.EndCursor = &edges[len(edges)-1].Cursor
+	}
+
+	return Connection[Model]{
+		Edges:      edges,
+		PageInfo:   info,
+		TotalCount: list.Count,
+	}, nil
+}
@@ -5,16 +5,32 @@ import (
 	"strings"
 
 	"encoding/base64"
+	"encoding/json"
 	"github.com/pkg/errors"
 )
 
 const prefix = "cursor_"
 
+// partSeparator separates the value of a [Cursor] from its keys and
+// fingerprint in a token. It is not in the URL-safe base64 alphabet, so it
+// cannot appear in any part.
+const partSeparator = "."
+
 // Cursor is an opaque identifier for a record in a data store. Determining what
 // the cursor does or how to decode it is left to the implementing store. It
 // serializes into a URL-safe token.
 type Cursor struct {
 	value []byte
+
+	// keys are the JSON-encoded values of the record's sort fields, when it was
+	// listed in an order other than by ID.
+	keys []json.RawMessage
+
+	// fingerprint identifies the query the cursor was produced by, if any.
+	fingerprint string
+
+	// end marks an [EndCursor].
+	end bool
 }
 
 // NewCursor instantiates [Cursor] with a known good value.
@@ -22,20 +38,81 @@ func NewCursor(value string) Cursor {
 	return Cursor{value: []byte(value)}
 }
 
-// Parse converts a valid serialized Cursor token back to a Cursor, else errors.
-func Parse(maybeCursorToken string) (*Cursor, error) {
+// EndCursor returns a cursor past the last record of every list. Listing
+// before it lists the last records, to page backwards from the end of a list.
+// It is not bound to a query, and has no token of its own.
+func EndCursor() Cursor {
+	return Cursor{end: true}
+}
+
+// IsEnd reports whether the [Cursor] is an [EndCursor].
+func (c Cursor) IsEnd() bool {
+	return c.end
+}
+
+// NewKeysetCursor instantiates [Cursor] with a known good value and the values
+// of the record's sort fields, in sort order.
+func NewKeysetCursor(value string, keys ...any) (Cursor, error) {
+	cursor := NewCursor(value)
+	for _, key := range keys {
+		raw, err := json.Marshal(key)
+		if err != nil {
+			return Cursor{}, errors.Wrap(err, "failed to encode cursor key")
+		}
+		cursor.keys = append(cursor.keys, raw)
+	}
+
+	return cursor, nil
+}
+
+// Parse converts a valid serialized Cursor token back to a Cursor, else errors
+// with [ErrInvalidCursor]. Tokens from a [CursorCodec] are parsed by passing
+// the codec; passing more than one fails with [ErrInvalidInput].
+func Parse(maybeCursorToken string, codec ...CursorCodec) (*Cursor, error) {
+	switch len(codec) {
+	case 0:
+	case 1:
+		return codec[0].Decode(maybeCursorToken)
+	default:
+		return nil, errors.Wrap(ErrInvalidInput, "more than one cursor codec")
+	}
+
 	sansPrefix, found := strings.CutPrefix(maybeCursorToken, prefix)
 	if !found {
-		return nil, errors.New("not a cursor")
+		return nil, errors.Wrap(ErrInvalidCursor, "missing prefix")
+	}
+
+	return parseBody(sansPrefix)
+}
+
+// parseBody is the inverse of [Cursor.body].
+func parseBody(body string) (*Cursor, error) {
+	parts := strings.Split(body, partSeparator)
+	if len(parts) > 3 {
+		return nil, errors.Wrap(ErrInvalidCursor, "unknown format")
 	}
 
-	enc, err := base64.URLEncoding.DecodeString(sansPrefix)
+	enc, err := base64.URLEncoding.DecodeString(parts[0])
 	if err != nil {
-		return nil, errors.Wrap(err, "not a cursor")
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
 	}
 
 	cursor := NewCursor(string(enc))
 
+	if len(parts) > 1 && parts[1] != "" {
+		enc, err := base64.URLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+		}
+		if err := json.Unmarshal(enc, &cursor.keys); err != nil {
+			return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+		}
+	}
+
+	if len(parts) > 2 {
+		cursor.fingerprint = parts[2]
+	}
+
 	return &cursor, nil
 }
 
@@ -44,12 +121,42 @@ func (c Cursor) Value() string {
 	return string(c.value)
 }
 
-// Token converts a Cursor into a URL-Safe string token.
+// Keys returns the JSON-encoded values of the sort fields of the record the
+// [Cursor] points to, in sort order. It is empty for records listed by ID.
+func (c Cursor) Keys() []json.RawMessage {
+	return c.keys
+}
+
+// Token converts a Cursor into a URL-Safe string token. The token is readable
+// by anyone holding it; use a [CursorCodec] for tokens handed to clients.
 func (c Cursor) Token() string {
-	return prefix + base64.URLEncoding.EncodeToString(c.value)
+	return prefix + c.body()
+}
+
+// body serializes the Cursor without a prefix.
+func (c Cursor) body() string {
+	parts := []string{base64.URLEncoding.EncodeToString(c.value), "", c.fingerprint}
+	if len(c.keys) > 0 {
+		// Marshaling already-encoded JSON cannot fail.
+		keys, _ := json.Marshal(c.keys)
+		parts[1] = base64.URLEncoding.EncodeToString(keys)
+	}
+
+	// Drop empty trailing parts, so cursors without them keep short tokens.
+	for len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+
+	return strings.Join(parts, partSeparator)
 }
 
 func (c Cursor) String() string {
+	if c.end {
+		return `[Cursor: end]`
+	} else if len(c.keys) > 0 {
+		return fmt.Sprintf(`[Cursor: %s %s]`, string(c.value), c.keys)
+	}
+
 	return fmt.Sprintf(`[Cursor: %s]`, string(c.value))
 }
 
@@ -0,0 +1,44 @@
+package store
+
+import "github.com/pkg/errors"
+
+// Errors returned by stores wrap one of these, so callers can branch on the
+// kind of failure with errors.Is regardless of the backing store.
+var (
+	// ErrNotFound is returned when an operation requires a record that does not
+	// exist. Operations that report "found" as a bool do not use it.
+	ErrNotFound = errors.New("record not found")
+
+	// ErrAlreadyExists is returned when a write conflicts with an existing
+	// record, e.g. creating a record with a duplicate ID.
+	ErrAlreadyExists = errors.New("record already exists")
+
+	// ErrInvalidPagination is returned when pagination parameters or cursors
+	// cannot be used.
+	ErrInvalidPagination = errors.New("invalid pagination parameters")
+
+	// ErrInvalidCursor is returned when a cursor token cannot be decoded or
+	// verified. It is also an [ErrInvalidPagination].
+	ErrInvalidCursor = errors.WithMessage(ErrInvalidPagination, "invalid cursor")
+
+	// ErrCursorMismatch is returned when a cursor is used with a different
+	// filter or sort than the query that produced it. It is also an
+	// [ErrInvalidCursor].
+	ErrCursorMismatch = errors.WithMessage(ErrInvalidCursor, "cursor does not match query")
+
+	// ErrVersionConflict is returned when writing a stale [Versioned] model.
+	// Errors are a [*VersionConflictError].
+	ErrVersionConflict = errors.New("record version conflict")
+
+	// ErrInvalidInput is returned when a request or record is malformed, or
+	// violates a constraint other than uniqueness.
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrCycle is returned when moving a tree node would make it its own
+	// ancestor.
+	ErrCycle = errors.New("tree node would be its own ancestor")
+
+	// ErrTokenExpired is returned when resuming a [Watcher] from a token whose
+	// changes are no longer kept.
+	ErrTokenExpired = errors.New("resume token expired")
+)
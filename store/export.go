@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Export writes every record from any [Lister] to w as JSON Lines, one record
+// per line in the lister's order, returning how many were written. Build turns
+// a cursor into the lister's parameters, as for [Each].
+func Export[Model Storable, Params Parameterized](
+	ctx context.Context,
+	w io.Writer,
+	l Lister[Model, Params],
+	build func(after *Cursor) Params,
+) (int, error) {
+	enc := json.NewEncoder(w)
+	exported := 0
+	err := Each(ctx, l, build, func(m Model) error {
+		if err := enc.Encode(m); err != nil {
+			return errors.Wrapf(err, "failed to export record %s", m.GetID())
+		}
+		exported++
+		return nil
+	})
+
+	return exported, err
+}
+
+// ImportOptions configure [Import].
+type ImportOptions struct {
+	// BatchSize is how many records are created at once by a [BatchCreator].
+	// Defaults to 500.
+	BatchSize int
+
+	// OnConflict resolves records that already exist, which otherwise stop the
+	// import with [ErrAlreadyExists]. It requires an [Upserter], except that
+	// [ConflictDoNothing] can skip records with any [Creator].
+	OnConflict *OnConflict
+}
+
+// ImportResult counts what [Import] did with each record.
+type ImportResult struct {
+	Created int
+	Updated int
+	Skipped int
+}
+
+// Import creates every record read from r, as written by [Export]. Records are
+// created in batches by a [BatchCreator], upserted by an [Upserter] when
+// resolving conflicts, or otherwise created one at a time.
+//
+// Import is not atomic: on error, earlier records or batches have been
+// created. Run it in a [Transactor] to import all or nothing.
+func Import[Model Storable](
+	ctx context.Context,
+	r io.Reader,
+	c Creator[Model],
+	opts ImportOptions,
+) (ImportResult, error) {
+	var result ImportResult
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	upserter, canUpsert := c.(Upserter[Model])
+	batcher, canBatch := c.(BatchCreator[Model])
+	if on := opts.OnConflict; on != nil && !canUpsert && on.Strategy != ConflictDoNothing {
+		return result, errors.Wrap(ErrInvalidInput, "resolving conflicts by overwriting requires an Upserter")
+	}
+
+	var batch []Model
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		created, err := batcher.CreateMany(ctx, batch)
+		if err != nil {
+			return errors.Wrap(err, "failed to import batch")
+		}
+		result.Created += len(created)
+		batch = nil
+		return nil
+	}
+
+	dec := json.NewDecoder(r)
+	for i := 1; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var m Model
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return result, errors.Wrapf(ErrInvalidInput, "failed to decode record %d: %s", i, err)
+		}
+
+		switch {
+		case opts.OnConflict != nil && canUpsert:
+			_, outcome, err := upserter.Upsert(ctx, m, *opts.OnConflict)
+			if err != nil {
+				return result, errors.Wrapf(err, "failed to import record %s", m.GetID())
+			}
+			switch outcome {
+			case UpsertInserted:
+				result.Created++
+			case UpsertUpdated:
+				result.Updated++
+			case UpsertSkipped:
+				result.Skipped++
+			}
+		case opts.OnConflict != nil:
+			_, err := c.Create(ctx, m)
+			if errors.Is(err, ErrAlreadyExists) {
+				result.Skipped++
+			} else if err != nil {
+				return result, errors.Wrapf(err, "failed to import record %s", m.GetID())
+			} else {
+				result.Created++
+			}
+		case canBatch:
+			batch = append(batch, m)
+			if len(batch) >= opts.BatchSize {
+				if err := flush(); err != nil {
+					return result, err
+				}
+			}
+		default:
+			if _, err := c.Create(ctx, m); err != nil {
+				return result, errors.Wrapf(err, "failed to import record %s", m.GetID())
+			}
+			result.Created++
+		}
+	}
+
+	return result, flush()
+}
@@ -0,0 +1,117 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pckilgore/app/node"
+	"pckilgore/app/pointers"
+	"pckilgore/app/store"
+	"pckilgore/app/store/gormstore"
+	"pckilgore/app/store/memorystore"
+	"pckilgore/app/store/pagination"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	build := func(after *store.Cursor) node.NodeParams {
+		return node.NodeParams{Pagination: pagination.MustNew(pagination.Params{
+			Limit: 10,
+			After: after,
+			Count: pointers.Make(store.CountNone),
+		})}
+	}
+
+	// A tree three levels deep.
+	var nodes []node.DatabaseNode
+	for i := 0; i < 3; i++ {
+		root := node.DatabaseNode{ID: fmt.Sprintf("root-%d", i), Name: fmt.Sprintf("root %d", i)}
+		nodes = append(nodes, root)
+		for j := 0; j < 4; j++ {
+			child := node.DatabaseNode{ID: fmt.Sprintf("%s/%d", root.ID, j), Name: "child", ParentID: &root.ID}
+			nodes = append(nodes, child)
+			for k := 0; k < 2; k++ {
+				nodes = append(nodes, node.DatabaseNode{ID: fmt.Sprintf("%s/%d", child.ID, k), Name: "grandchild", ParentID: &child.ID})
+			}
+		}
+	}
+
+	memory := memorystore.NewTreeStore[node.DatabaseNode, node.NodeParams]()
+	_, err := memory.CreateMany(ctx, nodes)
+	require.Nil(t, err)
+
+	db, err := gorm.Open(sqlite.Open("file:export?mode=memory&cache=shared"), &gorm.Config{})
+	require.Nil(t, err)
+	require.Nil(t, db.AutoMigrate(&node.DatabaseNode{}))
+	sql, err := gormstore.NewTreeStore[node.DatabaseNode, node.NodeParams](db)
+	require.Nil(t, err)
+
+	// Memory to SQLite.
+	var dump bytes.Buffer
+	exported, err := store.Export[node.DatabaseNode, node.NodeParams](ctx, &dump, memory, build)
+	require.Nil(t, err)
+	require.Equal(t, len(nodes), exported)
+	require.Equal(t, len(nodes), strings.Count(dump.String(), "\n"), "each record should be one line")
+
+	imported, err := store.Import[node.DatabaseNode](ctx, bytes.NewReader(dump.Bytes()), sql, store.ImportOptions{BatchSize: 7})
+	require.Nil(t, err)
+	require.Equal(t, store.ImportResult{Created: len(nodes)}, imported)
+
+	for _, root := range []string{"root-0", "root-2"} {
+		want, err := memory.ListDescendants(ctx, root)
+		require.Nil(t, err)
+		got, err := sql.ListDescendants(ctx, root)
+		require.Nil(t, err)
+		require.ElementsMatch(t, want.Flat(), got.Flat(), "trees should survive the round trip")
+	}
+
+	// And back again, into a fresh memory store.
+	var again bytes.Buffer
+	_, err = store.Export[node.DatabaseNode, node.NodeParams](ctx, &again, sql, build)
+	require.Nil(t, err)
+	require.Equal(t, dump.String(), again.String(), "dumps should not depend on the store")
+
+	fresh := memorystore.NewTreeStore[node.DatabaseNode, node.NodeParams]()
+	_, err = store.Import[node.DatabaseNode](ctx, bytes.NewReader(again.Bytes()), fresh, store.ImportOptions{})
+	require.Nil(t, err)
+	want, err := memory.ListAncestors(ctx, "root-1/3/1")
+	require.Nil(t, err)
+	got, err := fresh.ListAncestors(ctx, "root-1/3/1")
+	require.Nil(t, err)
+	require.ElementsMatch(t, want.Flat(), got.Flat())
+
+	t.Run("conflicts", func(t *testing.T) {
+		_, err := store.Import[node.DatabaseNode](ctx, bytes.NewReader(dump.Bytes()), sql, store.ImportOptions{})
+		require.ErrorIs(t, err, store.ErrAlreadyExists, "importing existing records should conflict by default")
+
+		imported, err := store.Import[node.DatabaseNode](ctx, bytes.NewReader(dump.Bytes()), sql, store.ImportOptions{
+			OnConflict: &store.OnConflict{Strategy: store.ConflictDoNothing},
+		})
+		require.Nil(t, err)
+		require.Equal(t, store.ImportResult{Skipped: len(nodes)}, imported)
+
+		renamed := strings.ReplaceAll(dump.String(), `"Name":"child"`, `"Name":"renamed"`)
+		imported, err = store.Import[node.DatabaseNode](ctx, strings.NewReader(renamed), sql, store.ImportOptions{
+			OnConflict: &store.OnConflict{Strategy: store.ConflictOverwriteFields, Fields: []string{"Name"}},
+		})
+		require.Nil(t, err)
+		require.Equal(t, store.ImportResult{Updated: len(nodes)}, imported)
+		child, _, err := sql.Retrieve(ctx, "root-0/0")
+		require.Nil(t, err)
+		require.Equal(t, "renamed", child.Name)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := store.Import[node.DatabaseNode](ctx, strings.NewReader(`{"ID":"ok"}`+"\n{not json\n"), fresh, store.ImportOptions{})
+		require.ErrorIs(t, err, store.ErrInvalidInput)
+	})
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Fingerprinter is implemented by parameters that describe their own filter.
+// Parameters that don't are fingerprinted by their exported fields instead.
+type Fingerprinter interface {
+	// Fingerprint returns a string that differs between any two filters that
+	// match different records. It must not depend on pagination.
+	Fingerprint() string
+}
+
+// Fingerprint identifies the filter, sort and [DeletedFilter] of p, but not its
+// limit or cursors, so that cursors can be bound to the query that produced
+// them.
+func Fingerprint(p Parameterized) (string, error) {
+	var filter []byte
+	if f, ok := p.(Fingerprinter); ok {
+		filter = []byte(f.Fingerprint())
+	} else {
+		var err error
+		filter, err = json.Marshal(p)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to fingerprint parameters")
+		}
+	}
+
+	sort, err := json.Marshal(p.Sort())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fingerprint parameters")
+	}
+
+	h := sha256.New()
+	h.Write(filter)
+	h.Write([]byte{0})
+	h.Write(sort)
+	if deleted := DeletedFilterOf(p); deleted != ExcludeDeleted {
+		h.Write([]byte{0, byte(deleted)})
+	}
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// Fingerprint returns the fingerprint of the query that produced the [Cursor],
+// or an empty string if it isn't bound to one.
+func (c Cursor) Fingerprint() string {
+	return c.fingerprint
+}
+
+// Bind returns a copy of the [Cursor] bound to a query's fingerprint.
+func (c Cursor) Bind(fingerprint string) Cursor {
+	c.fingerprint = fingerprint
+	return c
+}
+
+// Verify errors with [ErrCursorMismatch] if the [Cursor] is bound to a query
+// other than the one with fingerprint. Stores bind every cursor they issue, so
+// unbound cursors, such as ones stripped of their binding, error with
+// [ErrInvalidCursor].
+func (c Cursor) Verify(fingerprint string) error {
+	if c.fingerprint == "" {
+		return errors.Wrap(ErrInvalidCursor, "cursor is not bound to a query")
+	} else if c.fingerprint != fingerprint {
+		return errors.WithStack(ErrCursorMismatch)
+	}
+
+	return nil
+}
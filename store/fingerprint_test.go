@@ -0,0 +1,82 @@
+package store_test
+
+import (
+	"strings"
+	"testing"
+
+	"pckilgore/app/pointers"
+	"pckilgore/app/store"
+	"pckilgore/app/store/pagination"
+
+	"github.com/stretchr/testify/require"
+)
+
+type filterParams struct {
+	IDs *[]string
+
+	pagination.Pagination
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	fingerprint := func(p store.Parameterized) string {
+		f, err := store.Fingerprint(p)
+		require.Nil(t, err)
+		return f
+	}
+
+	base := filterParams{
+		IDs:        pointers.Make([]string{"a", "b"}),
+		Pagination: pagination.MustNew(pagination.Params{Limit: 10}),
+	}
+
+	t.Run("ignores pagination", func(t *testing.T) {
+		paged := base
+		paged.Pagination = pagination.MustNew(pagination.Params{
+			Limit: 20,
+			After: pointers.Make(store.NewCursor("a")),
+		})
+		require.Equal(t, fingerprint(base), fingerprint(paged))
+	})
+
+	t.Run("distinguishes filters", func(t *testing.T) {
+		filtered := base
+		filtered.IDs = pointers.Make([]string{"c"})
+		require.NotEqual(t, fingerprint(base), fingerprint(filtered))
+
+		filtered.IDs = nil
+		require.NotEqual(t, fingerprint(base), fingerprint(filtered))
+	})
+
+	t.Run("distinguishes sorts", func(t *testing.T) {
+		asc := base
+		asc.Pagination = pagination.MustNew(pagination.Params{Sort: []store.Sort{{Field: "Name"}}})
+		desc := base
+		desc.Pagination = pagination.MustNew(pagination.Params{Sort: []store.Sort{{Field: "Name", Desc: true}}})
+
+		require.NotEqual(t, fingerprint(base), fingerprint(asc))
+		require.NotEqual(t, fingerprint(asc), fingerprint(desc))
+	})
+
+	t.Run("survives tokens", func(t *testing.T) {
+		c := store.NewCursor("a").Bind(fingerprint(base))
+		parsed, err := store.Parse(c.Token())
+		require.Nil(t, err)
+		require.Equal(t, c, *parsed)
+		require.Nil(t, parsed.Verify(fingerprint(base)))
+
+		filtered := base
+		filtered.IDs = nil
+		require.ErrorIs(t, parsed.Verify(fingerprint(filtered)), store.ErrCursorMismatch)
+
+		// Stripping the binding from a token must not let it replay against
+		// another query.
+		token := c.Token()
+		stripped, err := store.Parse(token[:strings.LastIndex(token, ".")])
+		require.Nil(t, err)
+		require.Empty(t, stripped.Fingerprint())
+		require.ErrorIs(t, stripped.Verify(fingerprint(filtered)), store.ErrInvalidCursor)
+		require.ErrorIs(t, store.NewCursor("a").Verify(fingerprint(base)), store.ErrInvalidCursor, "unbound cursors should not verify")
+	})
+}
@@ -20,11 +20,11 @@ func NewCreator[D store.Storable](db *gorm.DB, r store.Retriever[D]) *Creator[D]
 // Create serializes a Model into the database. Returns the model after it's
 // written, in case the model pushes logic into the database.
 func (s *Creator[D]) Create(c context.Context, m D) (*D, error) {
-	db := s.db.WithContext(c)
+	db := conn(c, s.db)
 
-	result := db.Create(m)
+	result := db.Create(initialVersion(m))
 	if result.Error != nil {
-		return nil, errors.Wrap(result.Error, "failed to create record")
+		return nil, errors.Wrap(translateError(result.Error), "failed to create record")
 	}
 
 	// Re-fetch in case there are calculated fields.
@@ -37,3 +37,60 @@ func (s *Creator[D]) Create(c context.Context, m D) (*D, error) {
 
 	return retrieved, nil
 }
+
+// CreateMany serializes models into the database with multi-row inserts in a
+// single transaction, then re-fetches them in case the models push logic into
+// the database.
+func (s *Creator[D]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	if len(ms) == 0 {
+		return nil, nil
+	}
+
+	var versioned []D
+	for _, m := range ms {
+		versioned = append(versioned, initialVersion(m))
+	}
+
+	var created []D
+	err := conn(c, s.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(versioned, batchSize).Error; err != nil {
+			return errors.Wrap(translateError(err), "failed to create records")
+		}
+
+		retrieved := make(map[string]D, len(ms))
+		err := inBatches(len(ms), func(start, end int) error {
+			var ids []string
+			for _, m := range ms[start:end] {
+				ids = append(ids, m.GetID())
+			}
+
+			var batch []D
+			if err := tx.Where("id IN ?", ids).Find(&batch).Error; err != nil {
+				return errors.Wrap(err, "failed to retrieve newly-created models")
+			}
+			for _, m := range batch {
+				retrieved[m.GetID()] = m
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, m := range ms {
+			m, found := retrieved[m.GetID()]
+			if !found {
+				return errors.New("failed to find newly-created model")
+			}
+			created = append(created, m)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
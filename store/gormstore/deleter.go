@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Deleter[D store.Storable] struct {
@@ -16,16 +17,100 @@ func NewDeleter[D store.Storable](db *gorm.DB) *Deleter[D] {
 	return &Deleter[D]{db: db}
 }
 
-// Delete a model.
+// Delete a model, or mark it deleted if it is soft deletable.
 func (s *Deleter[D]) Delete(c context.Context, id string) (bool, error) {
-	db := s.db.WithContext(c)
+	db := conn(c, s.db)
 
-	result := db.Where("id = ?", id).Delete(new(D))
+	result := remove[D](db.Where("id = ?", id), store.Now())
 	if result.Error != nil {
-		return false, errors.Wrap(result.Error, "failed to delete record")
+		return false, errors.Wrap(translateError(result.Error), "failed to delete record")
 	} else if result.RowsAffected == 0 {
 		return false, nil
 	}
 
 	return true, nil
 }
+
+// DeleteMany deletes models in batches within a single transaction.
+func (s *Deleter[D]) DeleteMany(c context.Context, ids []string) (int, error) {
+	now := store.Now()
+	deleted := 0
+	err := conn(c, s.db).Transaction(func(tx *gorm.DB) error {
+		return inBatches(len(ids), func(start, end int) error {
+			result := remove[D](tx.Where("id IN ?", ids[start:end]), now)
+			if result.Error != nil {
+				return errors.Wrap(translateError(result.Error), "failed to delete records")
+			}
+			deleted += int(result.RowsAffected)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// DeleteVersion deletes a model only if it is at version.
+func (s *Deleter[D]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	column, versioned := versionColumn[D]()
+	if !versioned {
+		return false, errors.Wrapf(store.ErrInvalidInput, "%s are not versioned", (*new(D)).TableName())
+	}
+
+	db := conn(c, s.db)
+
+	result := remove[D](db.Where("id = ?", id).Where(clause.Eq{Column: column, Value: version}), store.Now())
+	if result.Error != nil {
+		return false, errors.Wrap(translateError(result.Error), "failed to delete record")
+	} else if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	var existing []D
+	result = db.Where("id = ?", id).Limit(1).Find(&existing)
+	if result.Error != nil {
+		return false, errors.Wrap(result.Error, "failed to retrieve record")
+	} else if len(existing) == 0 {
+		return false, nil
+	} else if any(existing[0]).(store.Versioned[D]).GetVersion() == version {
+		// Already deleted.
+		return false, nil
+	}
+
+	return false, errors.Wrap(conflict(existing[0], version), "failed to delete record")
+}
+
+// Restore clears the deletion mark of a soft deletable model.
+func (s *Deleter[D]) Restore(c context.Context, id string) (bool, error) {
+	column, soft := deletedColumn[D]()
+	if !soft {
+		return false, errors.Wrapf(store.ErrInvalidInput, "%s are not soft deletable", (*new(D)).TableName())
+	}
+
+	db := conn(c, s.db)
+
+	result := db.Model(new(D)).
+		Where("id = ?", id).
+		Where(clause.Neq{Column: column, Value: nil}).
+		Updates(deletion[D](nil))
+	if result.Error != nil {
+		return false, errors.Wrap(translateError(result.Error), "failed to restore record")
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// Purge permanently deletes a model, even if it is soft deletable.
+func (s *Deleter[D]) Purge(c context.Context, id string) (bool, error) {
+	db := conn(c, s.db)
+
+	result := db.Where("id = ?", id).Delete(new(D))
+	if result.Error != nil {
+		return false, errors.Wrap(translateError(result.Error), "failed to purge record")
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,61 @@
+package gormstore
+
+import (
+	"fmt"
+	"pckilgore/app/store"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// sqlStater is implemented by Postgres driver errors, e.g. *pgconn.PgError.
+type sqlStater interface {
+	SQLState() string
+}
+
+// translateError maps driver constraint violations onto store errors, keeping
+// the driver error in the chain.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	kind := errorKind(err)
+	if kind == nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+func errorKind(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return store.ErrAlreadyExists
+	}
+
+	var stater sqlStater
+	if errors.As(err, &stater) {
+		switch stater.SQLState() {
+		case "23505": // unique_violation
+			return store.ErrAlreadyExists
+		case "23502", "23503", "23514": // not_null, foreign_key, check
+			return store.ErrInvalidInput
+		}
+		return nil
+	}
+
+	// SQLite drivers only distinguish constraints in their messages.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed"),
+		strings.Contains(msg, "PRIMARY KEY constraint failed"):
+		return store.ErrAlreadyExists
+	case strings.Contains(msg, "NOT NULL constraint failed"),
+		strings.Contains(msg, "FOREIGN KEY constraint failed"),
+		strings.Contains(msg, "CHECK constraint failed"):
+		return store.ErrInvalidInput
+	}
+
+	return nil
+}
@@ -17,71 +17,189 @@ type GormParameters interface {
 
 func NewStore[D store.Storable, P GormParameters](db *gorm.DB) *Store[D, P] {
 	r := NewRetriever[D](db)
+	c := NewCreator[D](db, r)
+	d := NewDeleter[D](db)
+	l := NewLister[D, P](db)
 	return &Store[D, P]{
-		r: r,
-		c: NewCreator[D](db, r),
-		d: NewDeleter[D](db),
-		l: NewLister[D, P](db),
+		r:  r,
+		c:  c,
+		cm: c,
+		u:  NewUpdater[D](db, r),
+		up: NewUpserter[D](db, r),
+		d:  d,
+		dm: d,
+		dv: d,
+		sd: d,
+		l:  l,
+		i:  l,
 	}
 }
 
 type Store[D store.Storable, P GormParameters] struct {
-	r store.Retriever[D]
-	c store.Creator[D]
-	d store.Deleter[D]
-	l store.Lister[D, P]
+	r  store.Retriever[D]
+	c  store.Creator[D]
+	cm store.BatchCreator[D]
+	u  store.Updater[D]
+	up store.Upserter[D]
+	d  store.Deleter[D]
+	dm store.BatchDeleter[D]
+	dv store.VersionedDeleter
+	sd store.SoftDeleter
+	l  store.Lister[D, P]
+	i  store.Iterator[D, P]
 }
 
 func (s *Store[D, P]) Create(c context.Context, m D) (*D, error) {
 	return s.c.Create(c, m)
 }
 
+func (s *Store[D, P]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	return s.cm.CreateMany(c, ms)
+}
+
 func (s *Store[D, P]) Retrieve(c context.Context, id string) (*D, bool, error) {
 	return s.r.Retrieve(c, id)
 }
 
+func (s *Store[D, P]) Update(c context.Context, m D) (*D, bool, error) {
+	return s.u.Update(c, m)
+}
+
+func (s *Store[D, P]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	return s.u.Patch(c, m, fields...)
+}
+
+func (s *Store[D, P]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	return s.up.Upsert(c, m, on)
+}
+
 func (s *Store[D, P]) Delete(c context.Context, id string) (bool, error) {
 	return s.d.Delete(c, id)
 }
 
+func (s *Store[D, P]) DeleteMany(c context.Context, ids []string) (int, error) {
+	return s.dm.DeleteMany(c, ids)
+}
+
+func (s *Store[D, P]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	return s.dv.DeleteVersion(c, id, version)
+}
+
+func (s *Store[D, P]) Restore(c context.Context, id string) (bool, error) {
+	return s.sd.Restore(c, id)
+}
+
+func (s *Store[D, P]) Purge(c context.Context, id string) (bool, error) {
+	return s.sd.Purge(c, id)
+}
+
 func (s *Store[D, P]) List(c context.Context, params P) (store.ListResponse[D], error) {
 	return s.l.List(c, params)
 }
 
+func (s *Store[D, P]) Each(c context.Context, params P, fn func(D) error) error {
+	return s.i.Each(c, params, fn)
+}
+
 func NewTreeStore[D store.TreeStorable, P GormParameters](db *gorm.DB) (*TreeStore[D, P], error) {
 	err := db.Use(extraClausePlugin.New())
 	if err != nil {
 		return nil, errors.Wrap(err, "could not add required plugins to gorm store")
 	}
 
-	return &TreeStore[D, P]{s: NewStore[D, P](db), t: NewTree[D](db)}, nil
+	return &TreeStore[D, P]{s: NewStore[D, P](db), t: NewTree[D, P](db), tl: NewTreeLister[D, P](db)}, nil
 }
 
 type TreeStore[D store.TreeStorable, P GormParameters] struct {
-	s store.Store[D, P]
-	t store.Tree[D]
+	s  *Store[D, P]
+	t  store.Tree[D]
+	tl store.TreeLister[D, P]
 }
 
 func (s *TreeStore[D, P]) Create(c context.Context, m D) (*D, error) {
 	return s.s.Create(c, m)
 }
 
+func (s *TreeStore[D, P]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	return s.s.CreateMany(c, ms)
+}
+
 func (s *TreeStore[D, P]) Retrieve(c context.Context, id string) (*D, bool, error) {
 	return s.s.Retrieve(c, id)
 }
 
+func (s *TreeStore[D, P]) Update(c context.Context, m D) (*D, bool, error) {
+	return s.s.Update(c, m)
+}
+
+func (s *TreeStore[D, P]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	return s.s.Patch(c, m, fields...)
+}
+
+func (s *TreeStore[D, P]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	return s.s.Upsert(c, m, on)
+}
+
 func (s *TreeStore[D, P]) Delete(c context.Context, id string) (bool, error) {
 	return s.s.Delete(c, id)
 }
 
+func (s *TreeStore[D, P]) DeleteMany(c context.Context, ids []string) (int, error) {
+	return s.s.DeleteMany(c, ids)
+}
+
+func (s *TreeStore[D, P]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	return s.s.DeleteVersion(c, id, version)
+}
+
+func (s *TreeStore[D, P]) Restore(c context.Context, id string) (bool, error) {
+	return s.s.Restore(c, id)
+}
+
+func (s *TreeStore[D, P]) Purge(c context.Context, id string) (bool, error) {
+	return s.s.Purge(c, id)
+}
+
 func (s *TreeStore[D, P]) List(c context.Context, params P) (store.ListResponse[D], error) {
 	return s.s.List(c, params)
 }
 
-func (s *TreeStore[D, P]) ListDescendants(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	return s.t.ListDescendants(c, rootId)
+func (s *TreeStore[D, P]) Each(c context.Context, params P, fn func(D) error) error {
+	return s.s.Each(c, params, fn)
+}
+
+func (s *TreeStore[D, P]) ListDescendants(c context.Context, rootId string, o ...store.DescendantOptions) (store.TreeResponse[D], error) {
+	return s.t.ListDescendants(c, rootId, o...)
+}
+
+func (s *TreeStore[D, P]) ListAncestors(c context.Context, rootId string, o ...store.AncestorOptions) (store.TreeResponse[D], error) {
+	return s.t.ListAncestors(c, rootId, o...)
+}
+
+func (s *TreeStore[D, P]) Move(c context.Context, id string, parentID *string) (*D, bool, error) {
+	return s.t.Move(c, id, parentID)
+}
+
+func (s *TreeStore[D, P]) LowestCommonAncestor(c context.Context, a, b string) (store.CommonAncestor[D], bool, error) {
+	return s.t.LowestCommonAncestor(c, a, b)
+}
+
+func (s *TreeStore[D, P]) PathBetween(c context.Context, a, b string) (store.TreePath[D], bool, error) {
+	return s.t.PathBetween(c, a, b)
+}
+
+func (s *TreeStore[D, P]) ListChildren(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return s.tl.ListChildren(c, id, params)
+}
+
+func (s *TreeStore[D, P]) ListSiblings(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return s.tl.ListSiblings(c, id, params)
+}
+
+func (s *TreeStore[D, P]) ListRoots(c context.Context, params P) (store.ListResponse[D], error) {
+	return s.tl.ListRoots(c, params)
 }
 
-func (s *TreeStore[D, P]) ListAncestors(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	return s.t.ListAncestors(c, rootId)
+func (s *TreeStore[D, P]) ListLeaves(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return s.tl.ListLeaves(c, id, params)
 }
@@ -1,9 +1,12 @@
 package gormstore_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"pckilgore/app/node"
 	"pckilgore/app/pointers"
@@ -17,36 +20,67 @@ import (
 	"gorm.io/gorm"
 )
 
-func TestGormstore(t *testing.T) {
-	t.Parallel()
-
-	db, err := gorm.Open(sqlite.Open("file:/tmp/db?mode=memory&cache=shared"), &gorm.Config{})
+// newTestDB opens an in-memory database private to the test, migrated for
+// models.
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.Nil(t, err)
 
-	err = db.AutoMigrate(&node.DatabaseNode{})
+	err = db.AutoMigrate(models...)
 	require.Nil(t, err)
 
+	return db
+}
+
+// newTestStore opens a tree store of nodes on a database private to the test.
+func newTestStore(t *testing.T) (*gormstore.TreeStore[node.DatabaseNode, node.NodeParams], *gorm.DB) {
+	db := newTestDB(t, &node.DatabaseNode{})
 	nodeStore, err := gormstore.NewTreeStore[node.DatabaseNode, node.NodeParams](db)
 	require.Nil(t, err)
 
+	return nodeStore, db
+}
+
+// buildNode builds a root node with an ID unique to nonce.
+func buildNode(nonce int) node.DatabaseNode {
+	return buildTreeNode(nonce, nil)
+}
+
+// buildTreeNode builds a node under parentID with an ID unique to nonce.
+func buildTreeNode(nonce int, parentID *string) node.DatabaseNode {
+	return node.DatabaseNode{
+		ID:       fmt.Sprintf("%03d", nonce),
+		Name:     fmt.Sprintf("testing node %d", nonce),
+		ParentID: parentID,
+	}
+}
+
+// renameNode changes the name of a node.
+func renameNode(model node.DatabaseNode) (node.DatabaseNode, []string) {
+	model.Name = model.Name + " (updated)"
+	return model, []string{"Name"}
+}
+
+func nodeParams(p pagination.Params) node.NodeParams {
+	return node.NodeParams{Pagination: pagination.MustNew(p)}
+}
+
+func TestGormstore(t *testing.T) {
+	t.Parallel()
+
+	nodeStore, _ := newTestStore(t)
+
 	storetest.CreateTreeStoreTest[node.DatabaseNode, node.NodeParams](
 		t,
 		nodeStore,
-		func(nonce int, parentId *string) node.DatabaseNode {
-			return node.DatabaseNode{
-				ID:       fmt.Sprintf("%03d", nonce),
-				Name:     fmt.Sprintf("testing node %d", nonce),
-				ParentID: parentId,
-			}
-		},
+		buildTreeNode,
 		func(t *testing.T, model node.DatabaseNode) {
 			require.NotNil(t, model.ID)
 			require.Contains(t, model.Name, "")
 		},
-		func(limit int, after *store.Cursor, before *store.Cursor) node.NodeParams {
-			return node.NodeParams{
-				Pagination: pagination.New(pagination.Params{Limit: limit, After: after, Before: before}),
-			}
-		},
+		renameNode,
+		nodeParams,
+		[]string{"Name"},
 		func(d []node.DatabaseNode) node.NodeParams {
 			var ids []node.ID
 			for _, item := range d {
@@ -62,7 +96,7 @@ func TestGormstore(t *testing.T) {
 
 			return node.NodeParams{
 				IDs:        pointers.Make(ids),
-				Pagination: pagination.New(pagination.Params{}),
+				Pagination: pagination.MustNew(pagination.Params{}),
 			}
 		},
 		func(t *testing.T, params node.NodeParams, d []node.DatabaseNode) {
@@ -76,9 +110,126 @@ func TestGormstore(t *testing.T) {
 			require.Equal(t, len(d), len(*params.IDs))
 			require.Subset(t, gotIds, *params.IDs)
 		},
+		func(d []node.DatabaseNode) node.NodeParams {
+			var ids []node.ID
+			for _, item := range d {
+				w, err := node.Deserialize(&item)
+				require.Nil(t, err)
+				ids = append(ids, node.ID(w.ID))
+			}
+
+			return node.NodeParams{
+				IDs:        pointers.Make(ids),
+				Pagination: pagination.MustNew(pagination.Params{}),
+			}
+		},
+	)
+}
+
+func TestGormTransactor(t *testing.T) {
+	t.Parallel()
+
+	nodeStore, db := newTestStore(t)
+
+	storetest.CreateTransactorTest[node.DatabaseNode, node.NodeParams](
+		t,
+		gormstore.NewTransactor(db),
+		nodeStore,
+		buildNode,
+		renameNode,
+	)
+}
+
+func TestGormBatch(t *testing.T) {
+	t.Parallel()
+
+	nodeStore, _ := newTestStore(t)
+
+	storetest.CreateBatchTest[node.DatabaseNode, node.NodeParams](t, nodeStore, buildNode)
+}
+
+func TestGormUpsert(t *testing.T) {
+	t.Parallel()
+
+	nodeStore, _ := newTestStore(t)
+
+	storetest.CreateUpsertTest[node.DatabaseNode, node.NodeParams](t, nodeStore, buildNode, renameNode)
+}
+
+func TestGormVersioned(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t, &storetest.VersionedModel{})
+
+	storetest.CreateVersionedTest[storetest.VersionedModel, storetest.VersionedParams](
+		t,
+		gormstore.NewStore[storetest.VersionedModel, storetest.VersionedParams](db),
+		storetest.NewVersionedModel,
+		storetest.RenameVersionedModel,
+	)
+}
+
+func TestGormSoftDelete(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t, &storetest.DeletableModel{})
+
+	storetest.CreateSoftDeleteTest[storetest.DeletableModel, storetest.DeletableParams](
+		t,
+		gormstore.NewStore[storetest.DeletableModel, storetest.DeletableParams](db),
+		storetest.NewDeletableModel,
+		func(p pagination.Params, deleted store.DeletedFilter) storetest.DeletableParams {
+			return storetest.DeletableParams{Deleted: deleted, Pagination: pagination.MustNew(p)}
+		},
 	)
 }
 
+func TestGormIsolation(t *testing.T) {
+	t.Parallel()
+
+	nodeStore, _ := newTestStore(t)
+
+	storetest.CreateIsolationTest[node.DatabaseNode, node.NodeParams](
+		t,
+		nodeStore,
+		func(nonce int) node.DatabaseNode {
+			return buildTreeNode(nonce, pointers.Make(fmt.Sprintf("parent of %03d", nonce)))
+		},
+		nodeParams,
+	)
+}
+
+func TestGormCountEstimate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	nodeStore, _ := newTestStore(t)
+
+	var ids []node.ID
+	for i := 0; i < 30; i++ {
+		created, err := nodeStore.Create(ctx, buildNode(i))
+		require.Nil(t, err)
+		if i%3 == 0 {
+			ids = append(ids, node.ID(created.ID))
+		}
+	}
+
+	count := func(mode store.CountMode) int {
+		list, err := nodeStore.List(ctx, node.NodeParams{
+			IDs:        pointers.Make(ids),
+			Pagination: pagination.MustNew(pagination.Params{Limit: 5, Count: pointers.Make(mode)}),
+		})
+		require.Nil(t, err)
+		require.Len(t, list.Items, 5)
+		return list.Count
+	}
+
+	// SQLite has no planner estimates, so estimates fall back to counting the
+	// filtered rows exactly.
+	require.Equal(t, len(ids), count(store.CountExact))
+	require.Equal(t, len(ids), count(store.CountEstimate), "estimates should fall back to an exact count")
+}
+
 func TestHelpers(t *testing.T) {
 	t.Parallel()
 	var wmodels []node.DatabaseNode
@@ -136,3 +287,75 @@ func TestHelpers(t *testing.T) {
 		})
 	})
 }
+
+func TestGormWatch(t *testing.T) {
+	t.Parallel()
+
+	tree, db := newTestStore(t)
+	// Watchers poll while writers run, which shared cache SQLite only allows
+	// through a single connection.
+	sqlDB, err := db.DB()
+	require.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	watched := func() *gormstore.WatchedTreeStore[node.DatabaseNode, node.NodeParams] {
+		s, err := gormstore.NewWatchedTreeStore(db, tree, gormstore.WatchOptions{PollInterval: 10 * time.Millisecond})
+		require.Nil(t, err)
+		return s
+	}
+	nodeStore := watched()
+
+	_, err = gormstore.NewWatchedStore(
+		db,
+		gormstore.NewStore[node.DatabaseNode, node.NodeParams](db),
+		gormstore.WatchOptions{},
+		gormstore.WatchOptions{},
+	)
+	require.ErrorIs(t, err, store.ErrInvalidInput, "only one set of options can be passed")
+
+	storetest.CreateWatchTest[node.DatabaseNode, node.NodeParams](t, nodeStore, buildNode, renameNode)
+
+	t.Run("restart", func(t *testing.T) {
+		ctx := context.Background()
+		first, err := nodeStore.Create(ctx, node.DatabaseNode{ID: "before restart"})
+		require.Nil(t, err)
+
+		var token store.ResumeToken
+		err = nodeStore.Watch(ctx, "", func(change store.Change[node.DatabaseNode]) error {
+			if change.ID == first.ID {
+				token = change.Token()
+				return store.ErrStopIteration
+			}
+			return nil
+		})
+		require.Nil(t, err)
+
+		restarted := watched()
+		_, err = restarted.Create(ctx, node.DatabaseNode{ID: "after restart"})
+		require.Nil(t, err)
+
+		var resumed store.Change[node.DatabaseNode]
+		err = restarted.Watch(ctx, token, func(change store.Change[node.DatabaseNode]) error {
+			resumed = change
+			return store.ErrStopIteration
+		})
+		require.Nil(t, err)
+		require.Equal(t, "after restart", resumed.ID)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		ctx := context.Background()
+		failing := errors.New("failing")
+		err := gormstore.NewTransactor(db).RunInTx(ctx, func(c context.Context) error {
+			_, err := nodeStore.Create(c, node.DatabaseNode{ID: "rolled back"})
+			require.Nil(t, err)
+			return failing
+		})
+		require.ErrorIs(t, err, failing)
+
+		var count int64
+		err = db.Table("outbox").Where("record_id = ?", "rolled back").Count(&count).Error
+		require.Nil(t, err)
+		require.Zero(t, count, "rolled back writes should record no changes")
+	})
+}
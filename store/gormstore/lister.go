@@ -2,12 +2,11 @@ package gormstore
 
 import (
 	"context"
-	"pckilgore/app/pointers"
+	"encoding/json"
 	"pckilgore/app/store"
 
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type Lister[D store.Storable, P GormParameters] struct {
@@ -20,52 +19,78 @@ func NewLister[D store.Storable, P GormParameters](db *gorm.DB) *Lister[D, P] {
 
 // List a model.
 func (s *Lister[D, P]) List(c context.Context, params P) (store.ListResponse[D], error) {
-	db := s.db.WithContext(c)
+	return s.list(c, params, nil)
+}
+
+// list lists the models matching params, and scope if not nil.
+func (s *Lister[D, P]) list(c context.Context, params P, scope func(*gorm.DB) *gorm.DB) (store.ListResponse[D], error) {
+	db := conn(c, s.db)
 	limit := params.Limit()
 	reverse := false
 
 	after := params.After()
 	before := params.Before()
 	if after != nil && before != nil {
-		return store.ListResponse[D]{}, store.NewInvalidPaginationParamsErr(
-			"invalid parameters (only one of after or before can be set)",
+		return store.ListResponse[D]{}, errors.Wrap(
+			store.ErrInvalidPagination,
+			"only one of after or before can be set",
 		)
 	}
 
+	sorter, err := newSorter[D](s.db, params)
+	if err != nil {
+		return store.ListResponse[D]{}, err
+	}
+
 	model := *new(D)
 	table := model.TableName()
 	db = db.Table(table)
-	db = params.GormFilter(db)
+	db = params.GormFilter(db).Scopes(listed[D](store.DeletedFilterOf(params)))
+	if scope != nil {
+		db = db.Scopes(scope)
+	}
 
-	var count int64
-	result := db.Count(&count)
-	if result.Error != nil {
-		return store.ListResponse[D]{}, errors.Wrap(result.Error, "failed to get total with pagination")
+	count := int64(-1)
+	switch params.CountMode() {
+	case store.CountExact:
+		result := db.Count(&count)
+		if result.Error != nil {
+			return store.ListResponse[D]{}, errors.Wrap(result.Error, "failed to get total with pagination")
+		}
+	case store.CountEstimate:
+		count, err = estimateCount(db)
+		if err != nil {
+			return store.ListResponse[D]{}, errors.Wrap(err, "failed to estimate total with pagination")
+		}
 	}
 
 	if after != nil {
-		db = db.Where("id > ?", after.Value()).Order(
-			clause.OrderByColumn{Column: clause.Column{Name: "id"}},
-		)
+		db, err = sorter.seek(db, after, false)
 	} else if before != nil {
-		db = db.Where("id < ?", before.Value()).Order(
-			clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: true},
-		)
+		// Before the end is the last page, which needs no seek.
+		if !before.IsEnd() {
+			db, err = sorter.seek(db, before, true)
+		}
 		reverse = true
 	}
-
-	var leftToPaginate int64
-	result = db.Count(&leftToPaginate)
-	if result.Error != nil {
-		return store.ListResponse[D]{}, errors.Wrap(result.Error, "failed to get total with pagination")
+	if err != nil {
+		return store.ListResponse[D]{}, err
 	}
+	db = sorter.order(db, reverse)
 
+	// Fetch one extra row to learn whether there's more to paginate, rather
+	// than counting.
 	var modelList []D
-	result = db.Limit(limit).Find(&modelList)
+	result := db.Limit(limit + 1).Find(&modelList)
 	if result.Error != nil {
 		return store.ListResponse[D]{}, errors.Wrapf(result.Error, "failed to list %s", table)
 	}
 
+	more := len(modelList) > limit
+	if more {
+		modelList = modelList[:limit]
+	}
+
 	if reverse {
 		var reversed []D
 		for i := len(modelList) - 1; i >= 0; i-- {
@@ -74,29 +99,119 @@ func (s *Lister[D, P]) List(c context.Context, params P) (store.ListResponse[D],
 		modelList = reversed
 	}
 
+	cursors, err := sorter.cursors(modelList)
+	if err != nil {
+		return store.ListResponse[D]{}, err
+	}
+
+	var first, last *store.Cursor
+	if len(cursors) > 0 {
+		first = &cursors[0]
+		last = &cursors[len(cursors)-1]
+	}
+
 	var nextBefore *store.Cursor
 	var nextAfter *store.Cursor
 
-	more := len(modelList) < int(leftToPaginate)
-
 	if params.After() != nil && len(modelList) > 0 {
-		nextBefore = pointers.Make(store.NewCursor(modelList[0].GetID()))
+		nextBefore = first
 		if more {
-			nextAfter = pointers.Make(store.NewCursor(modelList[len(modelList)-1].GetID()))
+			nextAfter = last
 		}
 	} else if params.Before() != nil && len(modelList) > 0 {
-		nextAfter = pointers.Make(store.NewCursor(modelList[len(modelList)-1].GetID()))
+		if !before.IsEnd() {
+			nextAfter = last
+		}
 		if more {
-			nextBefore = pointers.Make(store.NewCursor(modelList[0].GetID()))
+			nextBefore = first
 		}
 	} else if more {
-		nextAfter = pointers.Make(store.NewCursor(modelList[len(modelList)-1].GetID()))
+		nextAfter = last
 	}
 
 	return store.ListResponse[D]{
-		Items:  modelList,
-		Count:  int(count),
-		After:  nextAfter,
-		Before: nextBefore,
+		Items:   modelList,
+		Cursors: cursors,
+		Count:   int(count),
+		After:   nextAfter,
+		Before:  nextBefore,
 	}, nil
 }
+
+// estimateCount asks the query planner how many rows a query will return. Only
+// Postgres is supported; other dialects are counted exactly.
+func estimateCount(db *gorm.DB) (int64, error) {
+	var count int64
+	if db.Dialector.Name() != "postgres" {
+		err := db.Count(&count).Error
+		return count, err
+	}
+
+	stmt := db.Session(&gorm.Session{DryRun: true}).Select("*").Find(&[]map[string]any{}).Statement
+
+	var explained string
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Raw("EXPLAIN (FORMAT JSON) "+stmt.SQL.String(), stmt.Vars...).
+		Row().
+		Scan(&explained)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to explain query")
+	}
+
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(explained), &plans); err != nil || len(plans) == 0 {
+		return 0, errors.New("failed to read query plan")
+	}
+
+	return int64(plans[0].Plan.Rows), nil
+}
+
+// Each streams every model matching params to fn in sort order, holding one
+// row in memory at a time. The limit is ignored. The query stays open while fn
+// runs, so fn should not write to the same database.
+func (s *Lister[D, P]) Each(c context.Context, params P, fn func(D) error) error {
+	if params.Before() != nil {
+		return errors.Wrap(store.ErrInvalidPagination, "cannot iterate before a cursor")
+	}
+
+	sorter, err := newSorter[D](s.db, params)
+	if err != nil {
+		return err
+	}
+
+	model := *new(D)
+	table := model.TableName()
+	db := params.GormFilter(conn(c, s.db).Table(table)).Scopes(listed[D](store.DeletedFilterOf(params)))
+	if after := params.After(); after != nil {
+		db, err = sorter.seek(db, after, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := sorter.order(db, false).Rows()
+	if err != nil {
+		return errors.Wrapf(err, "failed to iterate %s", table)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		var m D
+		if err := db.ScanRows(rows, &m); err != nil {
+			return errors.Wrapf(err, "failed to scan %s", table)
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrapf(rows.Err(), "failed to iterate %s", table)
+}
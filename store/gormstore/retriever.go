@@ -19,7 +19,7 @@ func NewRetriever[D store.Storable](db *gorm.DB) *Retriever[D] {
 
 // Retrieve a model.
 func (s *Retriever[D]) Retrieve(c context.Context, id string) (*D, bool, error) {
-	db := s.db.WithContext(c)
+	db := conn(c, s.db)
 	query := db.Unscoped()
 
 	var d D
@@ -0,0 +1,59 @@
+package gormstore
+
+import (
+	"pckilgore/app/store"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deletedColumn returns the column holding the deletion time of D, if D is
+// soft deletable.
+func deletedColumn[D any]() (clause.Column, bool) {
+	if soft, ok := any(*new(D)).(store.SoftDeletable[D]); ok {
+		return clause.Column{Name: soft.GetDeletedAtField()}, true
+	}
+
+	return clause.Column{}, false
+}
+
+// listed scopes a query to the records of D included in lists under filter.
+func listed[D any](filter store.DeletedFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		column, soft := deletedColumn[D]()
+		switch {
+		case !soft && filter == store.OnlyDeleted:
+			return db.Where("1 = 0")
+		case !soft || filter == store.IncludeDeleted:
+			return db
+		case filter == store.OnlyDeleted:
+			return db.Where(clause.Neq{Column: column, Value: nil})
+		}
+
+		return db.Where(clause.Eq{Column: column, Value: nil})
+	}
+}
+
+// remove deletes the records of D matched by db, or marks the live ones
+// deleted at if D is soft deletable.
+func remove[D any](db *gorm.DB, at time.Time) *gorm.DB {
+	column, soft := deletedColumn[D]()
+	if !soft {
+		return db.Delete(new(D))
+	}
+
+	return db.Model(new(D)).Where(clause.Eq{Column: column, Value: nil}).Updates(deletion[D](&at))
+}
+
+// deletion returns the columns written to mark a record of D deleted at, or
+// to restore it when at is nil.
+func deletion[D any](at *time.Time) map[string]any {
+	column, _ := deletedColumn[D]()
+	updates := map[string]any{column.Name: at}
+	if version, versioned := versionColumn[D](); versioned {
+		updates[version.Name] = gorm.Expr("? + 1", version)
+	}
+
+	return updates
+}
@@ -0,0 +1,137 @@
+package gormstore
+
+import (
+	"context"
+	"encoding/json"
+	"pckilgore/app/store"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var idColumn = clause.Column{Name: "id"}
+
+// sorter orders queries for D by the sort of a query, with the ID as the final
+// tiebreaker, and converts between records and cursors bound to that query.
+type sorter[D store.Storable] struct {
+	sorts       []store.Sort
+	fields      []*schema.Field
+	fingerprint string
+}
+
+func newSorter[D store.Storable](db *gorm.DB, params store.Parameterized) (*sorter[D], error) {
+	sorts := params.Sort()
+	fingerprint, err := store.Fingerprint(params)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(D)); err != nil {
+		return nil, errors.Wrap(err, "failed to parse model")
+	}
+
+	var fields []*schema.Field
+	for _, s := range sorts {
+		field := stmt.Schema.LookUpField(s.Field)
+		if field == nil || field.DBName == "" {
+			return nil, errors.Wrapf(store.ErrInvalidPagination, "cannot sort by unknown field %q", s.Field)
+		} else if field.FieldType.Kind() == reflect.Pointer {
+			return nil, errors.Wrapf(store.ErrInvalidPagination, "cannot sort by nullable field %q", s.Field)
+		}
+		fields = append(fields, field)
+	}
+
+	return &sorter[D]{sorts: sorts, fields: fields, fingerprint: fingerprint}, nil
+}
+
+// order sorts the query, or sorts it in reverse.
+func (s *sorter[D]) order(db *gorm.DB, reverse bool) *gorm.DB {
+	for i, field := range s.fields {
+		db = db.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: field.DBName},
+			Desc:   s.sorts[i].Desc != reverse,
+		})
+	}
+
+	return db.Order(clause.OrderByColumn{Column: idColumn, Desc: reverse})
+}
+
+// seek constrains the query to records strictly after the cursor in sort
+// order, or strictly before it when backwards.
+func (s *sorter[D]) seek(db *gorm.DB, c *store.Cursor, backwards bool) (*gorm.DB, error) {
+	if err := c.Verify(s.fingerprint); err != nil {
+		return nil, err
+	}
+
+	keys := c.Keys()
+	if len(keys) != len(s.fields) {
+		return nil, errors.Wrap(store.ErrCursorMismatch, "wrong number of sort keys")
+	}
+
+	// (a > ?) OR (a = ? AND b > ?) OR ... OR (a = ? AND b = ? AND id > ?)
+	var seeks []clause.Expression
+	var equal []clause.Expression
+	for i, field := range s.fields {
+		v := reflect.New(field.FieldType)
+		if err := json.Unmarshal(keys[i], v.Interface()); err != nil {
+			return nil, errors.Wrapf(store.ErrCursorMismatch, "bad sort key: %s", err)
+		}
+
+		column := clause.Column{Name: field.DBName}
+		seek := append(append([]clause.Expression{}, equal...), beyond(column, v.Elem().Interface(), s.sorts[i].Desc != backwards))
+		seeks = append(seeks, clause.And(seek...))
+		equal = append(equal, clause.Eq{Column: column, Value: v.Elem().Interface()})
+	}
+	seeks = append(seeks, clause.And(append(equal, beyond(idColumn, c.Value(), backwards))...))
+
+	// gorm joins a lone OR condition to the rest of the query with OR.
+	seek := clause.Or(seeks...)
+	if len(seeks) == 1 {
+		seek = seeks[0]
+	}
+
+	return db.Clauses(clause.Where{Exprs: []clause.Expression{seek}}), nil
+}
+
+// cursors returns a cursor pointing at each of items.
+func (s *sorter[D]) cursors(items []D) ([]store.Cursor, error) {
+	var cursors []store.Cursor
+	for _, item := range items {
+		c, err := s.cursor(item)
+		if err != nil {
+			return nil, err
+		}
+		cursors = append(cursors, *c)
+	}
+
+	return cursors, nil
+}
+
+func (s *sorter[D]) cursor(m D) (*store.Cursor, error) {
+	var keys []any
+	for _, field := range s.fields {
+		v, _ := field.ValueOf(context.Background(), reflect.ValueOf(m))
+		keys = append(keys, v)
+	}
+
+	c, err := store.NewKeysetCursor(m.GetID(), keys...)
+	if err != nil {
+		return nil, err
+	}
+	c = c.Bind(s.fingerprint)
+
+	return &c, nil
+}
+
+// beyond compares a column to a value in the direction of the sort.
+func beyond(column clause.Column, value any, desc bool) clause.Expression {
+	if desc {
+		return clause.Lt{Column: column, Value: value}
+	}
+
+	return clause.Gt{Column: column, Value: value}
+}
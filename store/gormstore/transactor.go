@@ -0,0 +1,38 @@
+package gormstore
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+type Transactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor runs units of work against db. Any gormstore sharing db's
+// connection pool joins the transaction when passed the callback's context.
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// RunInTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Nested calls use savepoints.
+func (t *Transactor) RunInTx(c context.Context, fn func(c context.Context) error) error {
+	return conn(c, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(c, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction carried by c when it was begun on db's
+// connection pool, else db itself. Store operations must get their connection
+// here to join transactions.
+func conn(c context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := c.Value(txKey{}).(*gorm.DB); ok && tx.Config.ConnPool == db.Config.ConnPool {
+		return tx.WithContext(c)
+	}
+
+	return db.WithContext(c)
+}
@@ -11,23 +11,63 @@ import (
 	"github.com/WinterYukky/gorm-extra-clause-plugin/exclause"
 )
 
-type layer struct {
+// layerRow is a single row of a recursive tree query: a model and its path
+// length from the root of the query. Both are scanned in one pass, since rows
+// cannot be scanned twice.
+type layerRow[D any] struct {
 	PathLength int
+	Model      D `gorm:"embedded"`
 }
 
-type Tree[D store.TreeStorable] struct {
+type Tree[D store.TreeStorable, P GormParameters] struct {
 	db *gorm.DB
 }
 
-func NewTree[D store.TreeStorable](db *gorm.DB) *Tree[D] {
-	return &Tree[D]{db: db}
+func NewTree[D store.TreeStorable, P GormParameters](db *gorm.DB) *Tree[D, P] {
+	return &Tree[D, P]{db: db}
 }
 
-func (s *Tree[D]) ListAncestors(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	db := s.db.WithContext(c)
+func (s *Tree[D, P]) ListAncestors(c context.Context, rootId string, o ...store.AncestorOptions) (store.TreeResponse[D], error) {
+	if len(o) > 1 {
+		return store.TreeResponse[D]{}, errors.Wrap(store.ErrInvalidInput, "more than one set of options passed to ListAncestors")
+	}
+	var opts store.AncestorOptions
+	if len(o) > 0 {
+		opts = o[0]
+	}
+
+	db := conn(c, s.db)
 	model := *new(D)
 
-	rows, err := db.Clauses(
+	parents := db.
+		Select(
+			"?.*, ?.path_length + 1 AS path_length",
+			clause.Table{Name: "possible_parents"},
+			clause.Table{Name: "ancestors"},
+		).
+		Table("ancestors").
+		Joins(
+			"join ? on ?.? = ?.?",
+			clause.Table{Name: model.TableName(), Alias: "possible_parents"},
+			clause.Table{Name: "ancestors"},
+			clause.Column{Name: model.GetParentIDField()},
+			clause.Table{Name: "possible_parents"},
+			clause.Column{Name: "id"},
+		)
+	listed := db.Table("ancestors")
+	if opts.Filter != nil {
+		matches, err := matching[D, P](db, opts.Filter)
+		if err != nil {
+			return store.TreeResponse[D]{}, errors.Wrap(err, "failed to list ancestors")
+		}
+		if opts.Filter.Prune {
+			parents = parents.Where("? IN (?)", clause.Column{Table: "possible_parents", Name: "id"}, matches)
+		} else {
+			listed = listed.Where("path_length = 0 OR id IN (?)", matches)
+		}
+	}
+
+	rows, err := listed.Clauses(
 		exclause.With{
 			Recursive: true,
 			CTEs: []exclause.CTE{
@@ -45,83 +85,108 @@ func (s *Tree[D]) ListAncestors(c context.Context, rootId string) (store.TreeRes
 								clause.Column{Name: "id"},
 								rootId,
 							).
-							Clauses(exclause.NewUnion(
-								"ALL ?",
-								db.
-									Select(
-										"?.*, ?.path_length + 1 AS path_length",
-										clause.Table{Name: "possible_parents"},
-										clause.Table{Name: "ancestors"},
-									).
-									Table("ancestors").
-									Joins(
-										"join ? on ?.? = ?.?",
-										clause.Table{Name: model.TableName(), Alias: "possible_parents"},
-										clause.Table{Name: "ancestors"},
-										clause.Column{Name: model.GetParentIDField()},
-										clause.Table{Name: "possible_parents"},
-										clause.Column{Name: "id"},
-									),
-							)),
+							Clauses(exclause.NewUnion("ALL ?", parents)),
 					},
 				},
 			},
 		},
 	).
-		Table("ancestors").
 		Order("path_length").
 		Rows()
-
-	defer rows.Close()
-
 	if err != nil {
 		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to get rows")
 	}
+	defer rows.Close()
 
-	count := 0
-	layerMap := make(map[int]*store.Layer[D])
+	var layers []store.Layer[D]
 	for rows.Next() {
-		// Grab path_length column.
-		var l layer
+		var l layerRow[D]
 		err := db.ScanRows(rows, &l)
 		if err != nil {
-			return store.TreeResponse[D]{}, errors.New("failed to scan path_length")
-		}
-
-		// Grab other cols into model.
-		var model D
-		err = db.ScanRows(rows, &model)
-		if err != nil {
-			return store.TreeResponse[D]{}, errors.New("failed to scan model")
-		}
-		count++
-
-		if layer, layerExists := layerMap[l.PathLength]; layerExists {
-			layer.Items = append(layer.Items, model)
-		} else {
-			layerMap[l.PathLength] = &store.Layer[D]{
-				Items:      []D{model},
-				PathLength: l.PathLength,
-			}
+			return store.TreeResponse[D]{}, errors.Wrap(err, "failed to scan model")
 		}
+		layers = append(layers, store.Layer[D]{Items: []D{l.Model}, PathLength: l.PathLength})
 	}
 
-	layers := make([]store.Layer[D], len(layerMap))
-	for _, v := range layerMap {
-		layers[v.PathLength] = *v
+	if err := rows.Err(); err != nil {
+		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to read rows")
+	} else if len(layers) == 0 {
+		return store.TreeResponse[D]{}, errors.Wrapf(store.ErrNotFound, "failed to find root %s", rootId)
 	}
 
 	return store.TreeResponse[D]{
 		Layers: layers,
-		Count:  count,
+		Count:  len(layers),
 	}, nil
 }
 
-func (s *Tree[D]) ListDescendants(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	db := s.db.WithContext(c)
+func (s *Tree[D, P]) ListDescendants(c context.Context, rootId string, o ...store.DescendantOptions) (store.TreeResponse[D], error) {
+	if len(o) > 1 {
+		return store.TreeResponse[D]{}, errors.Wrap(store.ErrInvalidInput, "more than one set of options passed to ListDescendants")
+	}
+	var opts store.DescendantOptions
+	if len(o) > 0 {
+		opts = o[0]
+	}
+	after, err := opts.Check(rootId)
+	if err != nil {
+		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to list descendants")
+	}
+	maxDepth := opts.MaxDepth
+	if after != nil {
+		maxDepth = after.PathLength
+	}
+
+	db := conn(c, s.db)
 	model := *new(D)
 
-	rows, err := db.Clauses(
+	// The depth bound stops the recursion, rather than filtering its result.
+	children := db.
+		Select(
+			"?.*, ?.path_length + 1 AS path_length",
+			clause.Table{Name: "possible_children"},
+			clause.Table{Name: "descendants"},
+		).
+		Table("descendants").
+		Joins(
+			"join ? on ?.? = ?.?",
+			clause.Table{Name: model.TableName(), Alias: "possible_children"},
+			clause.Table{Name: "descendants"},
+			clause.Column{Name: "id"},
+			clause.Table{Name: "possible_children"},
+			clause.Column{Name: model.GetParentIDField()},
+		)
+	if maxDepth > 0 {
+		children = children.Where("? < ?", clause.Column{Table: "descendants", Name: "path_length"}, maxDepth)
+	}
+	listed := db.Table("descendants")
+	if opts.Filter != nil {
+		matches, err := matching[D, P](db, opts.Filter)
+		if err != nil {
+			return store.TreeResponse[D]{}, errors.Wrap(err, "failed to list descendants")
+		}
+		if opts.Filter.Prune {
+			children = children.Where("? IN (?)", clause.Column{Table: "possible_children", Name: "id"}, matches)
+		} else {
+			listed = listed.Where("path_length = 0 OR id IN (?)", matches)
+		}
+	}
+
+	// Layers are ranked to take one more node than the limits, so that
+	// store.TrimLayers can tell which were cut short.
+	if after != nil {
+		listed = listed.Where("path_length = ? AND id > ?", after.PathLength, after.ID)
+	}
+	if opts.LayerLimit > 0 {
+		listed = db.
+			Table("(?) AS ranked", listed.Select("*, ROW_NUMBER() OVER (PARTITION BY path_length ORDER BY id) AS layer_position")).
+			Where("layer_position <= ?", opts.LayerLimit+1)
+	}
+	if opts.MaxNodes > 0 {
+		listed = listed.Limit(opts.MaxNodes + 1)
+	}
+
+	rows, err := listed.Clauses(
 		exclause.With{
 			Recursive: true,
 			CTEs: []exclause.CTE{
@@ -139,74 +204,147 @@ func (s *Tree[D]) ListDescendants(c context.Context, rootId string) (store.TreeR
 								clause.Column{Name: "id"},
 								rootId,
 							).
-							Clauses(exclause.NewUnion(
-								"ALL ?",
-								db.
-									Select(
-										"?.*, ?.path_length + 1 AS path_length",
-										clause.Table{Name: "possible_children"},
-										clause.Table{Name: "descendants"},
-									).
-									Table("descendants").
-									Joins(
-										"join ? on ?.? = ?.?",
-										clause.Table{Name: model.TableName(), Alias: "possible_children"},
-										clause.Table{Name: "descendants"},
-										clause.Column{Name: "id"},
-										clause.Table{Name: "possible_children"},
-										clause.Column{Name: model.GetParentIDField()},
-									),
-							)),
+							Clauses(exclause.NewUnion("ALL ?", children)),
 					},
 				},
 			},
 		},
 	).
-		Table("descendants").
 		Order("path_length").
+		Order("id").
 		Rows()
-
-	defer rows.Close()
-
 	if err != nil {
 		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to get rows")
 	}
+	defer rows.Close()
 
-	count := 0
-	layerMap := make(map[int]*store.Layer[D])
+	var layers []store.Layer[D]
 	for rows.Next() {
-		// Grab path_length column.
-		var l layer
+		var l layerRow[D]
 		err := db.ScanRows(rows, &l)
 		if err != nil {
-			return store.TreeResponse[D]{}, errors.New("failed to scan path_length")
+			return store.TreeResponse[D]{}, errors.Wrap(err, "failed to scan model")
 		}
 
-		// Grab other cols into model.
-		var model D
-		err = db.ScanRows(rows, &model)
+		if len(layers) > 0 && layers[len(layers)-1].PathLength == l.PathLength {
+			layers[len(layers)-1].Items = append(layers[len(layers)-1].Items, l.Model)
+		} else {
+			layers = append(layers, store.Layer[D]{Items: []D{l.Model}, PathLength: l.PathLength})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to read rows")
+	} else if len(layers) == 0 {
+		// Continuing a layer lists nothing past its end, but the root must
+		// still exist.
+		var roots int64
+		err := db.Table(model.TableName()).Where("id = ?", rootId).Count(&roots).Error
 		if err != nil {
-			return store.TreeResponse[D]{}, errors.New("failed to scan model")
+			return store.TreeResponse[D]{}, errors.Wrap(err, "failed to find root")
+		} else if roots == 0 {
+			return store.TreeResponse[D]{}, errors.Wrapf(store.ErrNotFound, "failed to find root %s", rootId)
 		}
-		count++
+	}
 
-		if layer, layerExists := layerMap[l.PathLength]; layerExists {
-			layer.Items = append(layer.Items, model)
-		} else {
-			layerMap[l.PathLength] = &store.Layer[D]{
-				Items:      []D{model},
-				PathLength: l.PathLength,
+	return store.TrimLayers(rootId, opts, layers), nil
+}
+
+func (s *Tree[D, P]) LowestCommonAncestor(c context.Context, a, b string) (store.CommonAncestor[D], bool, error) {
+	path, found, err := s.PathBetween(c, a, b)
+	if err != nil || !found {
+		return store.CommonAncestor[D]{}, false, err
+	}
+
+	return path.CommonAncestor(), true, nil
+}
+
+// PathBetween lists the ancestors of both nodes in one transaction, so a
+// concurrent move cannot tear the path on databases with snapshot reads.
+func (s *Tree[D, P]) PathBetween(c context.Context, a, b string) (store.TreePath[D], bool, error) {
+	var path store.TreePath[D]
+	var found bool
+	err := conn(c, s.db).Transaction(func(tx *gorm.DB) error {
+		c := context.WithValue(c, txKey{}, tx)
+
+		ancestorsA, err := s.ListAncestors(c, a)
+		if err != nil {
+			return errors.Wrap(err, "failed to find path")
+		}
+		ancestorsB, err := s.ListAncestors(c, b)
+		if err != nil {
+			return errors.Wrap(err, "failed to find path")
+		}
+
+		path, found = store.JoinAncestors(ancestorsA, ancestorsB)
+		return nil
+	})
+	if err != nil {
+		return store.TreePath[D]{}, false, err
+	}
+
+	return path, found, nil
+}
+
+// Move updates only the parent column, and version of versioned models, so
+// works with any model. The new parent's ancestors are checked for the node in
+// the same transaction, which prevents cycles as long as moves are serialized;
+// databases that run transactions concurrently must lock the rows involved.
+func (s *Tree[D, P]) Move(c context.Context, id string, parentID *string) (*D, bool, error) {
+	r := NewRetriever[D](s.db)
+	var moved *D
+	err := conn(c, s.db).Transaction(func(tx *gorm.DB) error {
+		c := context.WithValue(c, txKey{}, tx)
+
+		if _, found, err := r.Retrieve(c, id); err != nil || !found {
+			return err
+		}
+
+		if parentID != nil {
+			ancestors, err := s.ListAncestors(c, *parentID)
+			if errors.Is(err, store.ErrNotFound) {
+				return errors.Wrapf(store.ErrNotFound, "failed to find parent %s", *parentID)
+			} else if err != nil {
+				return errors.Wrap(err, "failed to list ancestors of parent")
+			}
+			for _, ancestor := range ancestors.Flat() {
+				if ancestor.GetID() == id {
+					return errors.Wrapf(store.ErrCycle, "failed to move %s under %s", id, *parentID)
+				}
 			}
 		}
+
+		updates := map[string]any{(*new(D)).GetParentIDField(): parentID}
+		if version, versioned := versionColumn[D](); versioned {
+			updates[version.Name] = gorm.Expr("? + 1", version)
+		}
+		result := tx.Model(new(D)).Where("id = ?", id).Updates(updates)
+		if result.Error != nil {
+			return errors.Wrap(translateError(result.Error), "failed to move record")
+		}
+
+		var err error
+		moved, _, err = r.Retrieve(c, id)
+		if err != nil {
+			return errors.Wrap(err, "failed to retrieve moved model")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
 	}
 
-	layers := make([]store.Layer[D], len(layerMap))
-	for _, v := range layerMap {
-		layers[v.PathLength] = *v
+	return moved, moved != nil, nil
+}
+
+// matching returns a query for the IDs of the nodes the filter's params would
+// list. Only the store's own params can filter, since others may not apply to
+// its table.
+func matching[D store.Storable, P GormParameters](db *gorm.DB, f *store.TreeFilter) (*gorm.DB, error) {
+	params, ok := f.Params.(P)
+	if !ok {
+		return nil, errors.Wrapf(store.ErrInvalidInput, "%T cannot filter %s", f.Params, (*new(D)).TableName())
 	}
 
-	return store.TreeResponse[D]{
-		Layers: layers,
-		Count:  count,
-	}, nil
+	return params.GormFilter(db.Model(new(D)).Select("id")).Scopes(listed[D](store.DeletedFilterOf(params))), nil
 }
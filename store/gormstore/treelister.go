@@ -0,0 +1,135 @@
+package gormstore
+
+import (
+	"context"
+	"pckilgore/app/store"
+
+	"github.com/WinterYukky/gorm-extra-clause-plugin/exclause"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TreeLister lists nodes by their place in a tree with the queries of a
+// [Lister], constrained by the indexed parent column.
+type TreeLister[D store.TreeStorable, P GormParameters] struct {
+	db *gorm.DB
+	l  *Lister[D, P]
+	r  store.Retriever[D]
+}
+
+func NewTreeLister[D store.TreeStorable, P GormParameters](db *gorm.DB) *TreeLister[D, P] {
+	return &TreeLister[D, P]{db: db, l: NewLister[D, P](db), r: NewRetriever[D](db)}
+}
+
+func (s *TreeLister[D, P]) ListChildren(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	if _, err := s.node(c, id); err != nil {
+		return store.ListResponse[D]{}, errors.Wrap(err, "failed to list children")
+	}
+
+	return s.l.list(c, params, func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: parentColumn[D](), Value: id})
+	})
+}
+
+func (s *TreeLister[D, P]) ListSiblings(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	node, err := s.node(c, id)
+	if err != nil {
+		return store.ListResponse[D]{}, errors.Wrap(err, "failed to list siblings")
+	}
+
+	var parentID any
+	if parent := node.GetParentID(); parent != nil {
+		parentID = *parent
+	}
+
+	return s.l.list(c, params, func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where(clause.Eq{Column: parentColumn[D](), Value: parentID}).
+			Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: "id"}, Value: id})
+	})
+}
+
+func (s *TreeLister[D, P]) ListRoots(c context.Context, params P) (store.ListResponse[D], error) {
+	return s.l.list(c, params, func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: parentColumn[D](), Value: nil})
+	})
+}
+
+// ListLeaves finds the nodes under id with a recursive query, keeping those no
+// node names as its parent.
+func (s *TreeLister[D, P]) ListLeaves(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	if _, err := s.node(c, id); err != nil {
+		return store.ListResponse[D]{}, errors.Wrap(err, "failed to list leaves")
+	}
+
+	db := conn(c, s.db)
+	model := *new(D)
+	table := model.TableName()
+	parent := model.GetParentIDField()
+
+	descendants := db.
+		Clauses(exclause.With{
+			Recursive: true,
+			CTEs: []exclause.CTE{
+				{
+					Name:    "descendants",
+					Columns: []string{"id"},
+					Subquery: exclause.Subquery{
+						DB: db.
+							Table(table).
+							Select("id").
+							Where(clause.Eq{Column: clause.Column{Name: parent}, Value: id}).
+							Clauses(exclause.NewUnion(
+								"ALL ?",
+								db.
+									Table("descendants").
+									Select("?.?", clause.Table{Name: "children"}, clause.Column{Name: "id"}).
+									Joins(
+										"join ? on ?.? = ?.?",
+										clause.Table{Name: table, Alias: "children"},
+										clause.Table{Name: "children"},
+										clause.Column{Name: parent},
+										clause.Table{Name: "descendants"},
+										clause.Column{Name: "id"},
+									),
+							)),
+					},
+				},
+			},
+		}).
+		Table("descendants").
+		Select("id")
+	childless := db.
+		Table(table+" AS children").
+		Select("1").
+		Where("?.? = ?.?",
+			clause.Table{Name: "children"},
+			clause.Column{Name: parent},
+			clause.Table{Name: table},
+			clause.Column{Name: "id"},
+		)
+
+	return s.l.list(c, params, func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("? IN (?)", clause.Column{Table: table, Name: "id"}, descendants).
+			Where("NOT EXISTS (?)", childless)
+	})
+}
+
+// node retrieves the node with id, or errors with [store.ErrNotFound].
+func (s *TreeLister[D, P]) node(c context.Context, id string) (D, error) {
+	node, found, err := s.r.Retrieve(c, id)
+	if err != nil {
+		return *new(D), err
+	} else if !found {
+		return *new(D), errors.Wrapf(store.ErrNotFound, "failed to find node %s", id)
+	}
+
+	return *node, nil
+}
+
+// parentColumn returns the column holding the parent ID of D.
+func parentColumn[D store.TreeStorable]() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: (*new(D)).GetParentIDField()}
+}
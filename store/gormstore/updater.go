@@ -0,0 +1,114 @@
+package gormstore
+
+import (
+	"context"
+	"pckilgore/app/store"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Updater[D store.Storable] struct {
+	db *gorm.DB
+	r  store.Retriever[D]
+}
+
+func NewUpdater[D store.Storable](db *gorm.DB, r store.Retriever[D]) *Updater[D] {
+	return &Updater[D]{db: db, r: r}
+}
+
+// Update overwrites every column of a model, including zero values.
+func (s *Updater[D]) Update(c context.Context, m D) (*D, bool, error) {
+	return s.update(c, m, "*")
+}
+
+// Patch overwrites only the columns backing the named fields of a model.
+func (s *Updater[D]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	if _, err := columns[D](s.db, fields); err != nil {
+		return nil, false, err
+	}
+
+	if len(fields) == 0 {
+		return s.r.Retrieve(c, m.GetID())
+	}
+
+	return s.update(c, m, fields...)
+}
+
+func (s *Updater[D]) update(c context.Context, m D, fields ...string) (*D, bool, error) {
+	db := conn(c, s.db).Model(new(D)).Where("id = ?", m.GetID())
+
+	// Versioned models are only written at the version they were read, and
+	// advance it.
+	v, versioned := any(m).(store.Versioned[D])
+	if versioned {
+		column, _ := versionColumn[D]()
+		db = db.Where(clause.Eq{Column: column, Value: v.GetVersion()})
+		m = v.WithVersion(v.GetVersion() + 1)
+		if fields[0] != "*" {
+			fields = append(append([]string{}, fields...), column.Name)
+		}
+	}
+
+	result := db.Select(fields).Updates(m)
+	if result.Error != nil {
+		return nil, false, errors.Wrap(translateError(result.Error), "failed to update record")
+	} else if result.RowsAffected == 0 && !versioned {
+		return nil, false, nil
+	} else if result.RowsAffected == 0 {
+		existing, found, err := s.r.Retrieve(c, m.GetID())
+		if err != nil || !found {
+			return nil, false, err
+		}
+		return nil, false, errors.Wrap(conflict(*existing, v.GetVersion()), "failed to update record")
+	}
+
+	// Re-fetch in case there are calculated fields.
+	retrieved, found, err := s.r.Retrieve(c, m.GetID())
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to retrieve updated model")
+	} else if !found {
+		return nil, false, errors.New("failed to find updated model")
+	}
+
+	return retrieved, true, nil
+}
+
+// columns returns the columns backing the named fields of D, or errors with
+// [store.ErrInvalidInput] if any field is unknown. Fields are named as in Go,
+// like the memory store's, so column names are unknown.
+func columns[D any](db *gorm.DB, fields []string) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(D)); err != nil {
+		return nil, errors.Wrap(err, "failed to parse model")
+	}
+
+	var columns []string
+	for _, field := range fields {
+		f, ok := stmt.Schema.FieldsByName[field]
+		if !ok || f.DBName == "" {
+			return nil, errors.Wrapf(store.ErrInvalidInput, "cannot write unknown field %q", field)
+		}
+		columns = append(columns, f.DBName)
+	}
+
+	return columns, nil
+}
+
+// updatableColumns returns every column of D but its primary key.
+func updatableColumns[D any](db *gorm.DB) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(D)); err != nil {
+		return nil, errors.Wrap(err, "failed to parse model")
+	}
+
+	var columns []string
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName != "" && !field.PrimaryKey {
+			columns = append(columns, field.DBName)
+		}
+	}
+
+	return columns, nil
+}
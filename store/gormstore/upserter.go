@@ -0,0 +1,102 @@
+package gormstore
+
+import (
+	"context"
+	"pckilgore/app/store"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Upserter[D store.Storable] struct {
+	db *gorm.DB
+	r  store.Retriever[D]
+}
+
+func NewUpserter[D store.Storable](db *gorm.DB, r store.Retriever[D]) *Upserter[D] {
+	return &Upserter[D]{db: db, r: r}
+}
+
+// Upsert inserts a model with an ON CONFLICT clause for the strategy.
+//
+// Whether an overwritten model existed is checked in the same transaction, so
+// under weak isolation two concurrent upserts of a new model may both report
+// inserting it. The write itself is atomic regardless.
+func (s *Upserter[D]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	var overwrite []string
+	var err error
+	switch on.Strategy {
+	case store.ConflictDoNothing:
+	case store.ConflictOverwrite:
+		overwrite, err = updatableColumns[D](s.db)
+	case store.ConflictOverwriteFields:
+		overwrite, err = columns[D](s.db, on.Fields)
+	default:
+		return nil, 0, errors.Wrapf(store.ErrInvalidInput, "unknown conflict strategy %d", on.Strategy)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	onConflict := clause.OnConflict{Columns: []clause.Column{idColumn}, DoNothing: len(overwrite) == 0}
+	version, versioned := versionColumn[D]()
+	for _, column := range overwrite {
+		if versioned && column == version.Name {
+			continue
+		}
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value:  clause.Column{Table: "excluded", Name: column},
+		})
+	}
+
+	// Versioned models advance from the stored version, rather than taking the
+	// version written.
+	if versioned && !onConflict.DoNothing {
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+			Column: version,
+			Value:  gorm.Expr("? + 1", clause.Column{Table: m.TableName(), Name: version.Name}),
+		})
+	}
+
+	var outcome store.UpsertOutcome
+	err = conn(c, s.db).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if !onConflict.DoNothing {
+			result := tx.Model(new(D)).Where("id = ?", m.GetID()).Count(&existing)
+			if result.Error != nil {
+				return errors.Wrap(result.Error, "failed to check for existing record")
+			}
+		}
+
+		result := tx.Clauses(onConflict).Create(initialVersion(m))
+		if result.Error != nil {
+			return errors.Wrap(translateError(result.Error), "failed to upsert record")
+		}
+
+		switch {
+		case onConflict.DoNothing && result.RowsAffected == 0:
+			outcome = store.UpsertSkipped
+		case existing > 0:
+			outcome = store.UpsertUpdated
+		default:
+			outcome = store.UpsertInserted
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Re-fetch in case there are calculated fields, or the write was skipped.
+	retrieved, found, err := s.r.Retrieve(c, m.GetID())
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to retrieve upserted model")
+	} else if !found {
+		return nil, 0, errors.New("failed to find upserted model")
+	}
+
+	return retrieved, outcome, nil
+}
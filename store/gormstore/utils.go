@@ -37,10 +37,33 @@ func ColumnInIDs[T ~string](columnName string, ids *[]T) func(*gorm.DB) *gorm.DB
 			c = append(c, clause.IN{Column: columnName, Values: IDs})
 		}
 
-		if len(c) > 0 {
+		// gorm joins a lone OR condition to the rest of the query with OR.
+		if len(c) == 1 {
+			db = db.Clauses(clause.Where{Exprs: c})
+		} else if len(c) > 1 {
 			db = db.Clauses(clause.Where{Exprs: []clause.Expression{clause.Or(c...)}})
 		}
 
 		return db
 	}
 }
+
+// batchSize bounds the rows a single statement writes or matches by ID, to
+// stay under driver limits on bound parameters.
+const batchSize = 500
+
+// inBatches calls fn with consecutive [start, end) ranges of at most batchSize
+// covering n items, stopping at the first error.
+func inBatches(n int, fn func(start, end int) error) error {
+	for start := 0; start < n; start += batchSize {
+		end := start + batchSize
+		if end > n {
+			end = n
+		}
+		if err := fn(start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package gormstore
+
+import (
+	"pckilgore/app/store"
+
+	"gorm.io/gorm/clause"
+)
+
+// versionColumn returns the column holding the version of D, if D is
+// versioned.
+func versionColumn[D any]() (clause.Column, bool) {
+	if v, ok := any(*new(D)).(store.Versioned[D]); ok {
+		return clause.Column{Name: v.GetVersionField()}, true
+	}
+
+	return clause.Column{}, false
+}
+
+// initialVersion returns m at version 1, if it is versioned.
+func initialVersion[D any](m D) D {
+	if v, ok := any(m).(store.Versioned[D]); ok {
+		return v.WithVersion(1)
+	}
+
+	return m
+}
+
+// conflict is the error for writing m at version when it is stored at
+// current.
+func conflict[D store.Storable](m D, version int64) error {
+	return &store.VersionConflictError{
+		ID:      m.GetID(),
+		Version: version,
+		Current: any(m).(store.Versioned[D]).GetVersion(),
+	}
+}
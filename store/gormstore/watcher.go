@@ -0,0 +1,417 @@
+package gormstore
+
+import (
+	"context"
+	"encoding/json"
+	"pckilgore/app/store"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// outboxEntry is a change recorded by a [WatchedStore], in the same
+// transaction as the write that made it.
+type outboxEntry struct {
+	Seq int64 `gorm:"primaryKey;autoIncrement"`
+
+	// Stream is the table of the changed record, so stores of many models can
+	// share the outbox.
+	Stream   string `gorm:"index;not null"`
+	RecordID string `gorm:"not null"`
+	Kind     store.ChangeKind
+
+	// Model is the record as written, as JSON, or nil if it was removed.
+	Model []byte
+
+	CreatedAt time.Time
+}
+
+func (outboxEntry) TableName() string {
+	return "outbox"
+}
+
+// WatchOptions configure a [WatchedStore].
+type WatchOptions struct {
+	// PollInterval is how often watchers check the outbox for changes once
+	// they have seen them all. Defaults to 100ms.
+	PollInterval time.Duration
+}
+
+// NewWatchedStore decorates s, a store on db, to record every change it makes
+// in an outbox table, in the same transaction as the write, which Watch then
+// streams. The outbox is created if it does not exist.
+//
+// Changes are ordered by when their rows were inserted, which is the order
+// they commit in when writers are serialized, as in SQLite. On databases
+// running writes concurrently, a watcher can read a change before an earlier
+// one commits and so skip it.
+//
+// Passing more than one set of options errors with [store.ErrInvalidInput].
+func NewWatchedStore[D store.Storable, P GormParameters](
+	db *gorm.DB,
+	s *Store[D, P],
+	o ...WatchOptions,
+) (*WatchedStore[D, P], error) {
+	if len(o) > 1 {
+		return nil, errors.Wrap(store.ErrInvalidInput, "more than one set of options passed to watched store")
+	}
+	var opts WatchOptions
+	if len(o) > 0 {
+		opts = o[0]
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 100 * time.Millisecond
+	}
+
+	if err := db.AutoMigrate(&outboxEntry{}); err != nil {
+		return nil, errors.Wrap(err, "failed to create outbox")
+	}
+
+	return &WatchedStore[D, P]{Store: s, db: db, tx: NewTransactor(db), opts: opts}, nil
+}
+
+// WatchedStore is a [Store] whose changes can be watched. See
+// [NewWatchedStore]. Changes are only recorded for writes made through it.
+type WatchedStore[D store.Storable, P GormParameters] struct {
+	*Store[D, P]
+
+	db   *gorm.DB
+	tx   *Transactor
+	opts WatchOptions
+}
+
+func (s *WatchedStore[D, P]) Create(c context.Context, m D) (*D, error) {
+	var created *D
+	err := s.write(c, []string{m.GetID()}, func(c context.Context) (err error) {
+		created, err = s.Store.Create(c, m)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (s *WatchedStore[D, P]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	ids := make([]string, len(ms))
+	for i, m := range ms {
+		ids[i] = m.GetID()
+	}
+
+	var created []D
+	err := s.write(c, ids, func(c context.Context) (err error) {
+		created, err = s.Store.CreateMany(c, ms)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (s *WatchedStore[D, P]) Update(c context.Context, m D) (*D, bool, error) {
+	var updated *D
+	var found bool
+	err := s.write(c, []string{m.GetID()}, func(c context.Context) (err error) {
+		updated, found, err = s.Store.Update(c, m)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return updated, found, nil
+}
+
+func (s *WatchedStore[D, P]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	var patched *D
+	var found bool
+	err := s.write(c, []string{m.GetID()}, func(c context.Context) (err error) {
+		patched, found, err = s.Store.Patch(c, m, fields...)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return patched, found, nil
+}
+
+func (s *WatchedStore[D, P]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	var upserted *D
+	var outcome store.UpsertOutcome
+	err := s.write(c, []string{m.GetID()}, func(c context.Context) (err error) {
+		upserted, outcome, err = s.Store.Upsert(c, m, on)
+		return err
+	})
+	if err != nil {
+		return nil, outcome, err
+	}
+
+	return upserted, outcome, nil
+}
+
+func (s *WatchedStore[D, P]) Delete(c context.Context, id string) (bool, error) {
+	return s.remove(c, []string{id}, func(c context.Context) (bool, error) {
+		return s.Store.Delete(c, id)
+	})
+}
+
+func (s *WatchedStore[D, P]) DeleteMany(c context.Context, ids []string) (int, error) {
+	var deleted int
+	err := s.write(c, ids, func(c context.Context) (err error) {
+		deleted, err = s.Store.DeleteMany(c, ids)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+func (s *WatchedStore[D, P]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	return s.remove(c, []string{id}, func(c context.Context) (bool, error) {
+		return s.Store.DeleteVersion(c, id, version)
+	})
+}
+
+func (s *WatchedStore[D, P]) Restore(c context.Context, id string) (bool, error) {
+	return s.remove(c, []string{id}, func(c context.Context) (bool, error) {
+		return s.Store.Restore(c, id)
+	})
+}
+
+func (s *WatchedStore[D, P]) Purge(c context.Context, id string) (bool, error) {
+	return s.remove(c, []string{id}, func(c context.Context) (bool, error) {
+		return s.Store.Purge(c, id)
+	})
+}
+
+// remove is write for the deletes, which report whether they did anything.
+func (s *WatchedStore[D, P]) remove(c context.Context, ids []string, fn func(c context.Context) (bool, error)) (bool, error) {
+	var done bool
+	err := s.write(c, ids, func(c context.Context) (err error) {
+		done, err = fn(c)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return done, nil
+}
+
+// write runs fn, which may only change the records with ids, in a
+// transaction that records what it changed in the outbox.
+func (s *WatchedStore[D, P]) write(c context.Context, ids []string, fn func(c context.Context) error) error {
+	return s.tx.RunInTx(c, func(c context.Context) error {
+		before, err := s.states(c, ids)
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+		after, err := s.states(c, ids)
+		if err != nil {
+			return err
+		}
+
+		stream := (*new(D)).TableName()
+		seen := make(map[string]bool, len(ids))
+		var entries []outboxEntry
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			change, changed := store.DiffChange(id, before[id], after[id])
+			if !changed {
+				continue
+			}
+			entry := outboxEntry{Stream: stream, RecordID: id, Kind: change.Kind}
+			if change.Model != nil {
+				if entry.Model, err = json.Marshal(change.Model); err != nil {
+					return errors.Wrapf(err, "failed to encode change to %s", id)
+				}
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		if err := conn(c, s.db).CreateInBatches(entries, batchSize).Error; err != nil {
+			return errors.Wrap(translateError(err), "failed to record changes")
+		}
+
+		return nil
+	})
+}
+
+// states loads the records with ids, including soft deleted ones, by ID.
+func (s *WatchedStore[D, P]) states(c context.Context, ids []string) (map[string]*D, error) {
+	states := make(map[string]*D, len(ids))
+	err := inBatches(len(ids), func(start, end int) error {
+		var found []D
+		err := conn(c, s.db).Unscoped().Where("id IN ?", ids[start:end]).Find(&found).Error
+		if err != nil {
+			return errors.Wrap(err, "failed to load records")
+		}
+		for i := range found {
+			states[found[i].GetID()] = &found[i]
+		}
+		return nil
+	})
+
+	return states, err
+}
+
+// Watch implements [store.Watcher]. The outbox keeps every change, so tokens
+// never expire, and the zero token replays them all.
+func (s *WatchedStore[D, P]) Watch(c context.Context, after store.ResumeToken, fn func(store.Change[D]) error) error {
+	var seq int64
+	if after != "" {
+		var err error
+		if seq, err = after.Seq(); err != nil {
+			return err
+		}
+	}
+
+	// Watching is long lived, so never joins a transaction, which would keep
+	// it from seeing changes committed by others.
+	db := s.db.WithContext(c)
+	stream := (*new(D)).TableName()
+	for {
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		var entries []outboxEntry
+		err := db.
+			Where("stream = ? AND seq > ?", stream, seq).
+			Order("seq").
+			Limit(batchSize).
+			Find(&entries).Error
+		if err != nil {
+			if cerr := c.Err(); cerr != nil {
+				return cerr
+			}
+			return errors.Wrap(err, "failed to read changes")
+		}
+
+		for _, entry := range entries {
+			change := store.Change[D]{Seq: entry.Seq, Kind: entry.Kind, ID: entry.RecordID}
+			if entry.Model != nil {
+				var m D
+				if err := json.Unmarshal(entry.Model, &m); err != nil {
+					return errors.Wrapf(err, "failed to decode change %d", entry.Seq)
+				}
+				change.Model = &m
+			}
+			if err := fn(change); err != nil {
+				if errors.Is(err, store.ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+			seq = entry.Seq
+		}
+
+		if len(entries) < batchSize {
+			select {
+			case <-c.Done():
+				return c.Err()
+			case <-time.After(s.opts.PollInterval):
+			}
+		}
+	}
+}
+
+// NewWatchedTreeStore decorates s, a tree store on db, as [NewWatchedStore]
+// does, also recording moves as updates of the node moved.
+func NewWatchedTreeStore[D store.TreeStorable, P GormParameters](
+	db *gorm.DB,
+	s *TreeStore[D, P],
+	o ...WatchOptions,
+) (*WatchedTreeStore[D, P], error) {
+	w, err := NewWatchedStore[D, P](db, s.s, o...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WatchedTreeStore[D, P]{TreeStore: s, w: w}, nil
+}
+
+// WatchedTreeStore is a [TreeStore] whose changes can be watched. See
+// [NewWatchedTreeStore]. Changes are only recorded for writes made through it.
+type WatchedTreeStore[D store.TreeStorable, P GormParameters] struct {
+	*TreeStore[D, P]
+
+	w *WatchedStore[D, P]
+}
+
+func (s *WatchedTreeStore[D, P]) Create(c context.Context, m D) (*D, error) {
+	return s.w.Create(c, m)
+}
+
+func (s *WatchedTreeStore[D, P]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	return s.w.CreateMany(c, ms)
+}
+
+func (s *WatchedTreeStore[D, P]) Update(c context.Context, m D) (*D, bool, error) {
+	return s.w.Update(c, m)
+}
+
+func (s *WatchedTreeStore[D, P]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	return s.w.Patch(c, m, fields...)
+}
+
+func (s *WatchedTreeStore[D, P]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	return s.w.Upsert(c, m, on)
+}
+
+func (s *WatchedTreeStore[D, P]) Delete(c context.Context, id string) (bool, error) {
+	return s.w.Delete(c, id)
+}
+
+func (s *WatchedTreeStore[D, P]) DeleteMany(c context.Context, ids []string) (int, error) {
+	return s.w.DeleteMany(c, ids)
+}
+
+func (s *WatchedTreeStore[D, P]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	return s.w.DeleteVersion(c, id, version)
+}
+
+func (s *WatchedTreeStore[D, P]) Restore(c context.Context, id string) (bool, error) {
+	return s.w.Restore(c, id)
+}
+
+func (s *WatchedTreeStore[D, P]) Purge(c context.Context, id string) (bool, error) {
+	return s.w.Purge(c, id)
+}
+
+// Move records the move as an update of the node moved. Only its parent
+// changes, so none is recorded for its descendants.
+func (s *WatchedTreeStore[D, P]) Move(c context.Context, id string, parentID *string) (*D, bool, error) {
+	var moved *D
+	var found bool
+	err := s.w.write(c, []string{id}, func(c context.Context) (err error) {
+		moved, found, err = s.TreeStore.Move(c, id, parentID)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return moved, found, nil
+}
+
+func (s *WatchedTreeStore[D, P]) Watch(c context.Context, after store.ResumeToken, fn func(store.Change[D]) error) error {
+	return s.w.Watch(c, after, fn)
+}
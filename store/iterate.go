@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrStopIteration can be returned by an iteration callback to stop iterating
+// early. [Iterate] and [Each] then return nil.
+var ErrStopIteration = errors.New("stop iteration")
+
+// Iterator is implemented by listers that can stream every record matching a
+// query more efficiently than by paging through it.
+type Iterator[Model Storable, Params Parameterized] interface {
+	// Each calls fn with every record matching p in sort order, starting after
+	// p's After cursor. The limit is ignored, and Before is not supported.
+	Each(ctx context.Context, p Params, fn func(Model) error) error
+}
+
+// Iterate calls fn with every page of records from any [Lister], following
+// After cursors until there are none. Build turns a cursor into the lister's
+// parameters, and is where filters, sorts and page sizes are applied; it is
+// passed nil for the first page. Since totals are not reported, build should
+// usually skip counting.
+//
+// Iterating stops at the first error from the lister or fn, or when ctx is
+// done.
+func Iterate[Model Storable, Params Parameterized](
+	ctx context.Context,
+	l Lister[Model, Params],
+	build func(after *Cursor) Params,
+	fn func(page []Model) error,
+) error {
+	var after *Cursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := l.List(ctx, build(after))
+		if err != nil {
+			return err
+		}
+
+		if len(page.Items) > 0 {
+			if err := fn(page.Items); err != nil {
+				if errors.Is(err, ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if page.After == nil {
+			return nil
+		}
+		after = page.After
+	}
+}
+
+// Each calls fn with every record from any [Lister], one at a time. Listers
+// that implement [Iterator] stream records with the parameters of the first
+// page; others are paged through with [Iterate].
+func Each[Model Storable, Params Parameterized](
+	ctx context.Context,
+	l Lister[Model, Params],
+	build func(after *Cursor) Params,
+	fn func(Model) error,
+) error {
+	if it, ok := l.(Iterator[Model, Params]); ok {
+		err := it.Each(ctx, build(nil), fn)
+		if errors.Is(err, ErrStopIteration) {
+			return nil
+		}
+		return err
+	}
+
+	return Iterate(ctx, l, build, func(page []Model) error {
+		for _, item := range page {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,113 @@
+package memorystore
+
+import "reflect"
+
+// Cloner is a model that deep copies itself. The memory store copies records
+// as they are written and read, so callers never share memory with stored
+// records. Other models are copied by reflection, which cannot reach
+// unexported fields, so models with unexported pointers, slices or maps should
+// be Cloners.
+type Cloner[D any] interface {
+	Clone() D
+}
+
+// cloner returns a func that deep copies values of T, or returns them as is if
+// they hold nothing to share.
+func cloner[T any]() func(m T) T {
+	if _, ok := any(*new(T)).(Cloner[T]); ok {
+		return func(m T) T {
+			return any(m).(Cloner[T]).Clone()
+		}
+	}
+
+	t := reflect.TypeOf(*new(T))
+	if t == nil || !shares(t, make(map[reflect.Type]bool)) {
+		return func(m T) T {
+			return m
+		}
+	}
+
+	return func(m T) T {
+		copied := reflect.New(t).Elem()
+		deepCopy(copied, reflect.ValueOf(m))
+		return copied.Interface().(T)
+	}
+}
+
+// shares reports whether copies of values of t can share memory through
+// exported fields.
+func shares(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Array:
+		return shares(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.IsExported() && shares(field.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// deepCopy sets dst, which must be settable, to a deep copy of src.
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.New(src.Type().Elem())
+		deepCopy(copied.Elem(), src.Elem())
+		dst.Set(copied)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(copied, src.Elem())
+		dst.Set(copied)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Cap()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			copied := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(copied, iter.Value())
+			dst.SetMapIndex(iter.Key(), copied)
+		}
+	case reflect.Struct:
+		// Copy every field, including unexported ones, then deep copy what can
+		// be reached.
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).IsExported() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
@@ -15,15 +15,44 @@ func NewCreator[D store.Storable](d *data[D]) *Creator[D] {
 	return &Creator[D]{d: d}
 }
 
-func (c *Creator[D]) Create(_ context.Context, storable D) (*D, error) {
-	c.d.mu.Lock()
-	defer c.d.mu.Unlock()
+func (c *Creator[D]) Create(ctx context.Context, storable D) (*D, error) {
+	defer c.d.lock(ctx)()
 
-	if _, exists := c.d.store[storable.GetID()]; exists {
-		return nil, errors.New("a record with that ID already exists")
+	if _, exists := c.d.get(storable.GetID()); exists {
+		return nil, errors.Wrapf(store.ErrAlreadyExists, "failed to create record %s", storable.GetID())
 	}
 
-	c.d.store[storable.GetID()] = storable
+	storable = initialVersion(storable)
+	if err := c.d.apply(set(storable.GetID(), storable)); err != nil {
+		return nil, errors.Wrap(err, "failed to create record")
+	}
 
 	return &storable, nil
 }
+
+// CreateMany creates every model in one locked pass, or none of them.
+func (c *Creator[D]) CreateMany(ctx context.Context, storables []D) ([]D, error) {
+	defer c.d.lock(ctx)()
+
+	batch := make(map[string]bool, len(storables))
+	for _, storable := range storables {
+		id := storable.GetID()
+		if _, exists := c.d.get(id); exists || batch[id] {
+			return nil, errors.Wrapf(store.ErrAlreadyExists, "failed to create record %s", id)
+		}
+		batch[id] = true
+	}
+
+	var created []D
+	var writes []write[D]
+	for _, storable := range storables {
+		storable = initialVersion(storable)
+		created = append(created, storable)
+		writes = append(writes, set(storable.GetID(), storable))
+	}
+	if err := c.d.apply(writes...); err != nil {
+		return nil, errors.Wrap(err, "failed to create records")
+	}
+
+	return created, nil
+}
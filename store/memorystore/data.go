@@ -1,18 +1,238 @@
 package memorystore
 
-import "sync"
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"pckilgore/app/store"
+)
+
+// data holds records as a series of immutable versions. Writes are serialized,
+// each making a new version that shares all it did not change with the last,
+// so reads load a version and neither block, nor are blocked by, writers.
 type data[T any] struct {
-	mu    sync.RWMutex
-	store map[string]T
+	// mu serializes writes.
+	mu      sync.Mutex
+	current atomic.Pointer[version[T]]
+
+	// indexes are maintained by every write.
+	indexes *indexes[T]
+
+	// clone deep copies records written and read, so they never share memory
+	// with callers.
+	clone func(m T) T
+
+	// log, if set, durably records every write before it is applied.
+	log *journal[T]
+
+	// feed keeps the changes writes make, for watchers.
+	feed *feed[T]
 }
 
 func NewData[T any](initial map[string]T) *data[T] {
-	if initial == nil {
-		initial = make(map[string]T)
+	d := &data[T]{indexes: newIndexes[T](), clone: cloner[T](), feed: newFeed[T]()}
+
+	v := &version[T]{records: newTreap[string, T](strings.Compare)}
+	for range d.indexes.all {
+		v.indexes = append(v.indexes, newEntries())
 	}
+	var writes []write[T]
+	for id, m := range initial {
+		writes = append(writes, set(id, d.clone(m)))
+	}
+	v, _ = v.with(d.indexes, writes)
+	d.current.Store(v)
+
+	return d
+}
+
+// version is the state of data at a point in time. It is never modified.
+type version[T any] struct {
+	records treap[string, T]
+
+	// indexes are the entries of each of data's indexes, by position.
+	indexes []entries
+}
 
-	return &data[T]{store: initial}
+func (v *version[T]) get(id string) (T, bool) {
+	return v.records.get(id)
+}
+
+// each calls fn with every record in ID order, until fn returns false.
+func (v *version[T]) each(fn func(m T) bool) {
+	v.records.ascend(nil, func(_ string, m T) bool {
+		return fn(m)
+	})
+}
+
+// all returns every record by ID.
+func (v *version[T]) all() map[string]T {
+	all := make(map[string]T, v.records.len())
+	v.records.ascend(nil, func(id string, m T) bool {
+		all[id] = m
+		return true
+	})
+
+	return all
+}
+
+// with returns a new version with writes applied to v, and the changes they
+// made.
+func (v *version[T]) with(ix *indexes[T], writes []write[T]) (*version[T], []store.Change[T]) {
+	next := &version[T]{records: v.records, indexes: append([]entries(nil), v.indexes...)}
+	var changes []store.Change[T]
+	for _, w := range writes {
+		var old *T
+		if existing, ok := next.records.get(w.ID); ok {
+			old = &existing
+		}
+		if change, changed := store.DiffChange(w.ID, old, w.Value); changed {
+			changes = append(changes, change)
+		}
+		for i, index := range ix.all {
+			next.indexes[i] = index.update(next.indexes[i], w.ID, old, w.Value)
+		}
+
+		if w.Value == nil {
+			next.records = next.records.delete(w.ID)
+		} else {
+			next.records = next.records.set(w.ID, *w.Value)
+		}
+	}
+
+	return next, changes
+}
+
+// get returns the current record with id, for writers. d.mu must be held.
+func (d *data[T]) get(id string) (T, bool) {
+	return d.current.Load().get(id)
+}
+
+type pinKey[T any] struct {
+	d *data[T]
+}
+
+type pin[T any] struct {
+	v atomic.Pointer[version[T]]
+}
+
+// read returns the version reads made with c see: the version pinned to c,
+// or the current version.
+func (d *data[T]) read(c context.Context) *version[T] {
+	if p, ok := c.Value(pinKey[T]{d: d}).(*pin[T]); ok {
+		if v := p.v.Load(); v != nil {
+			return v
+		}
+	}
+
+	return d.current.Load()
+}
+
+// pin returns c with the current version pinned for reads, and a func that
+// unpins it.
+func (d *data[T]) pin(c context.Context) (context.Context, func()) {
+	p := new(pin[T])
+	p.v.Store(d.current.Load())
+
+	return context.WithValue(c, pinKey[T]{d: d}, p), func() { p.v.Store(nil) }
 }
 
 type InitialData[T any] map[string]T
+
+// write is a change to a single record: it is set to Value, or removed if
+// Value is nil.
+type write[T any] struct {
+	ID    string `json:"id"`
+	Value *T     `json:"value,omitempty"`
+}
+
+func set[T any](id string, m T) write[T] {
+	return write[T]{ID: id, Value: &m}
+}
+
+func unset[T any](id string) write[T] {
+	return write[T]{ID: id}
+}
+
+// apply logs, then applies, writes as a single atomic change. Nothing is
+// applied if they cannot be logged. d.mu must be held.
+func (d *data[T]) apply(writes ...write[T]) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	stored := make([]write[T], len(writes))
+	for i, w := range writes {
+		stored[i] = w
+		if w.Value != nil {
+			stored[i] = set(w.ID, d.clone(*w.Value))
+		}
+	}
+	next, changes := d.current.Load().with(d.indexes, stored)
+
+	// Writers hold d.mu, so the feed numbers these changes next.
+	if d.log != nil {
+		if err := d.log.append(d.feed.last()+int64(len(changes)), writes); err != nil {
+			return err
+		}
+	}
+
+	d.current.Store(next)
+	d.feed.append(changes)
+
+	return nil
+}
+
+type lockKey[T any] struct {
+	d *data[T]
+}
+
+// lock locks d for writes, unless c is in a transaction already holding the
+// lock, returning a func that unlocks it.
+func (d *data[T]) lock(c context.Context) (unlock func()) {
+	if held, _ := c.Value(lockKey[T]{d: d}).(bool); held {
+		return func() {}
+	}
+	d.mu.Lock()
+
+	return d.mu.Unlock
+}
+
+// snapshot locks d for writes, except those made with the returned context,
+// and captures its current version. Release unlocks it, first restoring the
+// snapshot unless keep.
+func (d *data[T]) snapshot(c context.Context) (context.Context, func(keep bool)) {
+	unlock := d.lock(c)
+	snapshot := d.current.Load()
+
+	return context.WithValue(c, lockKey[T]{d: d}, true), func(keep bool) {
+		defer unlock()
+		if keep {
+			return
+		}
+
+		// Restore by writes that undo every change since the snapshot. If they
+		// cannot be logged the log stops accepting writes, so restoring memory
+		// alone cannot diverge any further from it.
+		current := d.current.Load()
+		var writes []write[T]
+		current.records.ascend(nil, func(id string, _ T) bool {
+			if _, ok := snapshot.get(id); !ok {
+				writes = append(writes, unset[T](id))
+			}
+			return true
+		})
+		snapshot.records.ascend(nil, func(id string, m T) bool {
+			if existing, ok := current.get(id); !ok || !reflect.DeepEqual(existing, m) {
+				writes = append(writes, set(id, m))
+			}
+			return true
+		})
+		if d.apply(writes...) != nil {
+			d.current.Store(snapshot)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package memorystore
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"pckilgore/app/store"
 )
 
@@ -14,13 +15,96 @@ func NewDeleter[D store.Storable](d *data[D]) *Deleter[D] {
 	return &Deleter[D]{d: d}
 }
 
-func (deleter *Deleter[D]) Delete(_ context.Context, id string) (bool, error) {
-	deleter.d.mu.Lock()
-	defer deleter.d.mu.Unlock()
-	if _, exists := deleter.d.store[id]; exists {
-		delete(deleter.d.store, id)
-		return true, nil
+func (deleter *Deleter[D]) Delete(c context.Context, id string) (bool, error) {
+	defer deleter.d.lock(c)()
+
+	removed, err := deleter.d.remove(id, store.Now())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete record")
+	}
+
+	return removed, nil
+}
+
+func (deleter *Deleter[D]) DeleteMany(c context.Context, ids []string) (int, error) {
+	defer deleter.d.lock(c)()
+
+	at := store.Now()
+	seen := make(map[string]bool, len(ids))
+	var writes []write[D]
+	for _, id := range ids {
+		if w, ok := deleter.d.removal(id, at); ok && !seen[id] {
+			writes = append(writes, w)
+		}
+		seen[id] = true
+	}
+	if err := deleter.d.apply(writes...); err != nil {
+		return 0, errors.Wrap(err, "failed to delete records")
+	}
+
+	return len(writes), nil
+}
+
+func (deleter *Deleter[D]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	if _, ok := any(*new(D)).(store.Versioned[D]); !ok {
+		return false, errors.Wrapf(store.ErrInvalidInput, "%s are not versioned", (*new(D)).TableName())
+	}
+
+	defer deleter.d.lock(c)()
+
+	existing, exists := deleter.d.get(id)
+	if !exists {
+		return false, nil
+	}
+
+	current := any(existing).(store.Versioned[D]).GetVersion()
+	if current != version {
+		return false, errors.Wrap(
+			&store.VersionConflictError{ID: id, Version: version, Current: current},
+			"failed to delete record",
+		)
+	}
+
+	removed, err := deleter.d.remove(id, store.Now())
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete record")
+	}
+
+	return removed, nil
+}
+
+func (deleter *Deleter[D]) Restore(c context.Context, id string) (bool, error) {
+	if _, ok := any(*new(D)).(store.SoftDeletable[D]); !ok {
+		return false, errors.Wrapf(store.ErrInvalidInput, "%s are not soft deletable", (*new(D)).TableName())
+	}
+
+	defer deleter.d.lock(c)()
+
+	existing, exists := deleter.d.get(id)
+	if !exists {
+		return false, nil
+	}
+
+	soft := any(existing).(store.SoftDeletable[D])
+	if soft.GetDeletedAt() == nil {
+		return false, nil
+	}
+	if err := deleter.d.apply(set(id, bumpVersion(existing, soft.WithDeletedAt(nil)))); err != nil {
+		return false, errors.Wrap(err, "failed to restore record")
+	}
+
+	return true, nil
+}
+
+func (deleter *Deleter[D]) Purge(c context.Context, id string) (bool, error) {
+	defer deleter.d.lock(c)()
+
+	if _, exists := deleter.d.get(id); !exists {
+		return false, nil
+	}
+	if err := deleter.d.apply(unset[D](id)); err != nil {
+		return false, errors.Wrap(err, "failed to purge record")
 	}
 
-	return false, nil
+	return true, nil
 }
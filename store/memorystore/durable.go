@@ -0,0 +1,180 @@
+package memorystore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"pckilgore/app/store"
+)
+
+// SyncPolicy is when a durable store flushes its log to disk. Writes are
+// always appended to the log before they are acknowledged; the policy only
+// decides how much a machine crash, rather than a process crash, can lose.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes every write before acknowledging it.
+	SyncAlways SyncPolicy = iota
+
+	// SyncPeriodic flushes writes in the background every SyncInterval.
+	SyncPeriodic
+
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+// DurableOptions configure a durable store.
+type DurableOptions struct {
+	Sync SyncPolicy
+
+	// SyncInterval is how often SyncPeriodic flushes. Defaults to a second.
+	SyncInterval time.Duration
+
+	// CompactInterval is how often the log is compacted into a snapshot in the
+	// background. With none, it is only compacted by Compact.
+	CompactInterval time.Duration
+}
+
+// durable keeps data in sync with a journal on disk, running background
+// flushes and compactions.
+type durable[T any] struct {
+	data *data[T]
+	log  *journal[T]
+
+	stop chan struct{}
+	done sync.WaitGroup
+	once sync.Once
+}
+
+func openDurable[T any](dir string, o []DurableOptions) (*durable[T], error) {
+	if len(o) > 1 {
+		return nil, errors.Wrap(store.ErrInvalidInput, "more than one set of options passed to durable store")
+	}
+	var opts DurableOptions
+	if len(o) > 0 {
+		opts = o[0]
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+
+	log, state, seq, err := openJournal[T](dir, opts.Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewData(state)
+	d.log = log
+	d.feed.seq = seq
+
+	durable := &durable[T]{data: d, log: log, stop: make(chan struct{})}
+	if opts.Sync == SyncPeriodic {
+		durable.every(opts.SyncInterval, log.flush)
+	}
+	if opts.CompactInterval > 0 {
+		durable.every(opts.CompactInterval, durable.compact)
+	}
+
+	return durable, nil
+}
+
+// every runs fn in the background at each interval until closed. Background
+// failures are sticky in the journal, so surface on the next write.
+func (d *durable[T]) every(interval time.Duration, fn func() error) {
+	d.done.Add(1)
+	go func() {
+		defer d.done.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				_ = fn()
+			}
+		}
+	}()
+}
+
+func (d *durable[T]) compact() error {
+	// Hold off writes, so the snapshot includes every append.
+	d.data.mu.Lock()
+	defer d.data.mu.Unlock()
+
+	return d.log.compact(d.data.feed.last(), d.data.current.Load().all())
+}
+
+func (d *durable[T]) close() error {
+	d.once.Do(func() { close(d.stop) })
+	d.done.Wait()
+
+	return d.log.close()
+}
+
+// OpenStore opens a memory store that persists to dir, creating it if needed.
+// Every write is appended to a log before it is applied, and the log is
+// replayed when the store is reopened. Models must round trip through JSON.
+//
+// Transactions are not atomic across crashes: a crash while a [Transactor] is
+// running can persist part of its unit of work. Nor are changes watchers have
+// seen, unless every write is synced: a machine crash can lose them, and
+// their resume tokens then resume from changes made after reopening.
+//
+// Passing more than one set of options errors with [store.ErrInvalidInput].
+func OpenStore[D store.Storable, P MemoryParams[D]](dir string, o ...DurableOptions) (*DurableStore[D, P], error) {
+	durable, err := openDurable[D](dir, o)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open durable store")
+	}
+
+	return &DurableStore[D, P]{Store: newStore[D, P](durable.data), durable: durable}, nil
+}
+
+// DurableStore is a [Store] that persists to disk. See [OpenStore].
+type DurableStore[D store.Storable, P MemoryParams[D]] struct {
+	*Store[D, P]
+
+	durable *durable[D]
+}
+
+// Compact writes every record to a snapshot and empties the log, so reopening
+// the store doesn't replay every write ever made.
+func (s *DurableStore[D, P]) Compact() error {
+	return s.durable.compact()
+}
+
+// Close flushes the log and stops background work. Later writes fail.
+func (s *DurableStore[D, P]) Close() error {
+	return s.durable.close()
+}
+
+// OpenTreeStore opens a memory tree store that persists to dir, as
+// [OpenStore].
+func OpenTreeStore[D store.TreeStorable, P MemoryParams[D]](dir string, o ...DurableOptions) (*DurableTreeStore[D, P], error) {
+	durable, err := openDurable[D](dir, o)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open durable store")
+	}
+
+	return &DurableTreeStore[D, P]{TreeStore: newTreeStore[D, P](durable.data), durable: durable}, nil
+}
+
+// DurableTreeStore is a [TreeStore] that persists to disk. See [OpenTreeStore].
+type DurableTreeStore[D store.TreeStorable, P MemoryParams[D]] struct {
+	*TreeStore[D, P]
+
+	durable *durable[D]
+}
+
+// Compact writes every record to a snapshot and empties the log.
+func (s *DurableTreeStore[D, P]) Compact() error {
+	return s.durable.compact()
+}
+
+// Close flushes the log and stops background work. Later writes fail.
+func (s *DurableTreeStore[D, P]) Close() error {
+	return s.durable.close()
+}
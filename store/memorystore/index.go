@@ -0,0 +1,306 @@
+package memorystore
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"pckilgore/app/store"
+)
+
+// Indexed is a model that declares secondary indexes, by Go field name, for
+// the memory store to maintain on write. Fields must be of a type that can be
+// sorted by. Pointer fields are indexed by the value they point to, or nil.
+// Unfiltered lists sorted first by an indexed field are paged through its
+// index.
+//
+// Tree models always maintain an index of children by parent, which serves
+// their tree queries and filters on their parent field, so do not need to
+// declare one for that. Their parent field is the one named like the column
+// of GetParentIDField, ignoring case and underscores.
+type Indexed interface {
+	MemoryIndexes() []string
+}
+
+// IndexFilter restricts a list to records whose indexed fields, by Go field
+// name, hold one of the given values. Values are converted to the type of the
+// field, and nil matches a nil pointer.
+type IndexFilter map[string][]any
+
+// IndexFilterer is implemented by params that restrict lists by indexed
+// fields. Lists are served from the indexes, rather than by filtering every
+// record, before MemoryFilter is applied to what remains.
+type IndexFilterer interface {
+	IndexFilter() IndexFilter
+}
+
+// index orders records by a key, such as the value of a field, then ID.
+type index[T any] struct {
+	key func(m T) any
+
+	// typ is the type of keys, which filter values are converted to.
+	typ reflect.Type
+}
+
+// indexEntry is a record in an index. Its key is nil or of the index's type.
+type indexEntry struct {
+	key any
+	id  string
+
+	// last, only set on entries walks start from, places the entry after
+	// every record with its key.
+	last bool
+}
+
+// entries is the content of an index.
+type entries = treap[indexEntry, struct{}]
+
+func newEntries() entries {
+	return newTreap[indexEntry, struct{}](func(a, b indexEntry) int {
+		if c := compareKeys(a.key, b.key); c != 0 {
+			return c
+		} else if a.last != b.last {
+			return compareOrdered(boolToInt(a.last), boolToInt(b.last))
+		}
+		return strings.Compare(a.id, b.id)
+	})
+}
+
+// compareKeys orders two keys of an index, with nil first.
+func compareKeys(a, b any) int {
+	if a == nil || b == nil {
+		return compareOrdered(boolToInt(a != nil), boolToInt(b != nil))
+	}
+	if a, ok := a.(string); ok {
+		return strings.Compare(a, b.(string))
+	}
+
+	return compareValues(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// fieldIndex indexes T by the named field, or panics if it cannot be.
+func fieldIndex[T any](name string) *index[T] {
+	field, ok := reflect.TypeOf(*new(T)).FieldByName(name)
+	typ := field.Type
+	if ok && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if !ok || !field.IsExported() || !sortable(typ) {
+		panic(fmt.Sprintf("memorystore: cannot index field %q of %T", name, *new(T)))
+	}
+
+	return &index[T]{
+		typ: typ,
+		key: func(m T) any {
+			v := reflect.ValueOf(m).FieldByIndex(field.Index)
+			if v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return nil
+				}
+				v = v.Elem()
+			}
+			return v.Interface()
+		},
+	}
+}
+
+// parentIndex indexes tree models by their parent ID, with roots under nil.
+func parentIndex[T any]() *index[T] {
+	return &index[T]{
+		typ: reflect.TypeOf(""),
+		key: func(m T) any {
+			if parent := any(m).(store.TreeStorable).GetParentID(); parent != nil {
+				return *parent
+			}
+			return nil
+		},
+	}
+}
+
+// keyOf converts a filter value to a key of the index.
+func (ix *index[T]) keyOf(value any) (any, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if v.Kind() != ix.typ.Kind() || !v.Type().ConvertibleTo(ix.typ) {
+		return nil, errors.Wrapf(store.ErrInvalidInput, "cannot filter %s by %T", ix.typ, value)
+	}
+
+	return v.Convert(ix.typ).Interface(), nil
+}
+
+// update returns e changed to index a record going from old to new, either of
+// which may be missing.
+func (ix *index[T]) update(e entries, id string, old *T, new *T) entries {
+	var oldKey, newKey any
+	if old != nil {
+		oldKey = ix.key(*old)
+	}
+	if new != nil {
+		newKey = ix.key(*new)
+	}
+	if old != nil && new != nil && compareKeys(oldKey, newKey) == 0 {
+		return e
+	}
+
+	if old != nil {
+		e = e.delete(indexEntry{key: oldKey, id: id})
+	}
+	if new != nil {
+		e = e.set(indexEntry{key: newKey, id: id}, struct{}{})
+	}
+
+	return e
+}
+
+// lookup returns the IDs of records in e whose key is k, in order.
+func lookup(e entries, k any) []string {
+	var ids []string
+	e.ascend(&indexEntry{key: k}, func(entry indexEntry, _ struct{}) bool {
+		if compareKeys(entry.key, k) != 0 {
+			return false
+		}
+		ids = append(ids, entry.id)
+		return true
+	})
+
+	return ids
+}
+
+// indexes are the secondary indexes maintained over data.
+type indexes[T any] struct {
+	all []*index[T]
+
+	// fields are positions in all of indexes declared by [Indexed] models, and
+	// of the children index for the parent field of tree models, by field
+	// name.
+	fields map[string]int
+
+	// children is the position in all of the index of tree models by parent,
+	// or -1.
+	children int
+}
+
+func newIndexes[T any]() *indexes[T] {
+	ix := &indexes[T]{fields: make(map[string]int), children: -1}
+	if tree, ok := any(*new(T)).(store.TreeStorable); ok {
+		ix.children = len(ix.all)
+		ix.all = append(ix.all, parentIndex[T]())
+		if field, ok := parentField[T](tree.GetParentIDField()); ok {
+			ix.fields[field] = ix.children
+		}
+	}
+	if indexed, ok := any(*new(T)).(Indexed); ok {
+		for _, field := range indexed.MemoryIndexes() {
+			if _, ok := ix.fields[field]; ok {
+				continue
+			}
+			ix.fields[field] = len(ix.all)
+			ix.all = append(ix.all, fieldIndex[T](field))
+		}
+	}
+
+	return ix
+}
+
+// parentField returns the name of the exported field of T backing the parent
+// column, if there is one.
+func parentField[T any](column string) (string, bool) {
+	name := strings.ReplaceAll(column, "_", "")
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(*new(T))) {
+		if field.IsExported() && !field.Anonymous && strings.EqualFold(field.Name, name) {
+			return field.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// filter returns the IDs of records in v matching every field of f, in order,
+// or false if f does not filter at all.
+func (ix *indexes[T]) filter(v *version[T], f IndexFilter) ([]string, bool, error) {
+	var matches []string
+	filtered := false
+	for field, values := range f {
+		if len(values) == 0 {
+			continue
+		}
+
+		i, ok := ix.fields[field]
+		if !ok {
+			return nil, false, errors.Wrapf(store.ErrInvalidInput, "cannot filter by unindexed field %q", field)
+		}
+
+		var ids []string
+		for _, value := range values {
+			k, err := ix.all[i].keyOf(value)
+			if err != nil {
+				return nil, false, err
+			}
+			ids = append(ids, lookup(v.indexes[i], k)...)
+		}
+		if len(values) > 1 {
+			ids = dedupe(ids)
+		}
+
+		if !filtered {
+			matches, filtered = ids, true
+			continue
+		}
+
+		matches = intersect(matches, ids)
+	}
+
+	return matches, filtered, nil
+}
+
+// childrenOf returns the IDs of the children of a tree model in v, in order.
+func (ix *indexes[T]) childrenOf(v *version[T], parentID string) []string {
+	return lookup(v.indexes[ix.children], parentID)
+}
+
+// roots returns the IDs of the tree models in v without a parent, in order.
+func (ix *indexes[T]) roots(v *version[T]) []string {
+	return lookup(v.indexes[ix.children], nil)
+}
+
+// intersect returns the IDs of a that are also in b, in the order of a.
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, id := range b {
+		in[id] = true
+	}
+
+	var both []string
+	for _, id := range a {
+		if in[id] {
+			both = append(both, id)
+		}
+	}
+
+	return both
+}
+
+// dedupe sorts ids, removing duplicates.
+func dedupe(ids []string) []string {
+	sort.Strings(ids)
+
+	var unique []string
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
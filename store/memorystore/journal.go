@@ -0,0 +1,252 @@
+package memorystore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	logFile      = "wal.jsonl"
+)
+
+// errClosed is returned when writing to a closed durable store.
+var errClosed = errors.New("durable store is closed")
+
+// journal is a write-ahead log of writes to data, one JSON batch per line,
+// compacted into a JSON snapshot of every record. Both keep the sequence
+// number of the last change made, so that the store's changes keep counting
+// up from it when reopened, rather than reusing numbers.
+//
+// A failed append is sticky: every later append fails too, since the log may
+// no longer match the data.
+type journal[T any] struct {
+	mu    sync.Mutex
+	dir   string
+	file  *os.File
+	sync  SyncPolicy
+	dirty bool
+	err   error
+}
+
+// journalEntry is a line of the log: a batch of writes, and the sequence
+// number of the last change they made.
+type journalEntry[T any] struct {
+	Seq    int64      `json:"seq"`
+	Writes []write[T] `json:"writes"`
+}
+
+// journalSnapshot is every record, and the sequence number of the last change
+// made to them.
+type journalSnapshot[T any] struct {
+	Seq     int64        `json:"seq"`
+	Records map[string]T `json:"records"`
+}
+
+// openJournal opens the journal in dir, creating it if needed, and replays it,
+// returning the records and the sequence number of the last change.
+func openJournal[T any](dir string, sync SyncPolicy) (*journal[T], map[string]T, int64, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, 0, errors.Wrap(err, "failed to create store directory")
+	}
+
+	state := journalSnapshot[T]{Records: make(map[string]T)}
+	snapshot, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, 0, errors.Wrap(err, "failed to read snapshot")
+	} else if err == nil {
+		if err := json.Unmarshal(snapshot, &state); err != nil {
+			return nil, nil, 0, errors.Wrap(err, "failed to decode snapshot")
+		}
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, 0, errors.Wrap(err, "failed to open log")
+	}
+
+	// Replay every complete line. A crash mid-append can only leave a partial
+	// final line, which was never acknowledged, so is dropped.
+	var offset int64
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			file.Close()
+			return nil, nil, 0, errors.Wrap(err, "failed to read log")
+		}
+
+		var entry journalEntry[T]
+		if err := json.Unmarshal(line, &entry); err != nil {
+			file.Close()
+			return nil, nil, 0, errors.Wrapf(err, "failed to decode log at offset %d", offset)
+		}
+		for _, w := range entry.Writes {
+			if w.Value == nil {
+				delete(state.Records, w.ID)
+			} else {
+				state.Records[w.ID] = *w.Value
+			}
+		}
+		if entry.Seq > state.Seq {
+			state.Seq = entry.Seq
+		}
+		offset += int64(len(line))
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, nil, 0, errors.Wrap(err, "failed to drop partial log entry")
+	}
+
+	return &journal[T]{dir: dir, file: file, sync: sync}, state.Records, state.Seq, nil
+}
+
+// append durably records a batch of writes, which bring the sequence number of
+// the last change to seq, according to the sync policy.
+func (j *journal[T]) append(seq int64, writes []write[T]) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return j.err
+	}
+
+	line, err := json.Marshal(journalEntry[T]{Seq: seq, Writes: writes})
+	if err != nil {
+		// Nothing was written, so the log is still usable.
+		return errors.Wrap(err, "failed to encode log entry")
+	}
+
+	// One write per entry, so a crash can only tear the final line.
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		j.err = errors.Wrap(err, "failed to append to log")
+		return j.err
+	}
+
+	if j.sync == SyncAlways {
+		if err := j.file.Sync(); err != nil {
+			j.err = errors.Wrap(err, "failed to sync log")
+			return j.err
+		}
+	} else {
+		j.dirty = true
+	}
+
+	return nil
+}
+
+// flush syncs appends made since the last sync.
+func (j *journal[T]) flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.flushLocked()
+}
+
+func (j *journal[T]) flushLocked() error {
+	if j.err != nil || !j.dirty {
+		return j.err
+	}
+
+	if err := j.file.Sync(); err != nil {
+		j.err = errors.Wrap(err, "failed to sync log")
+		return j.err
+	}
+	j.dirty = false
+
+	return nil
+}
+
+// compact replaces the snapshot with state, which must include every append,
+// and empties the log. Appends must be blocked until it returns.
+func (j *journal[T]) compact(seq int64, state map[string]T) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return j.err
+	}
+
+	encoded, err := json.Marshal(journalSnapshot[T]{Seq: seq, Records: state})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode snapshot")
+	}
+
+	// Replace the snapshot atomically. If we crash before the log is emptied,
+	// replaying it over the new snapshot rewrites the same values.
+	tmp := filepath.Join(j.dir, snapshotFile+".tmp")
+	if err := writeFileSync(tmp, encoded); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(j.dir, snapshotFile)); err != nil {
+		return errors.Wrap(err, "failed to replace snapshot")
+	}
+	if err := syncDir(j.dir); err != nil {
+		return err
+	}
+
+	if err := j.file.Truncate(0); err != nil {
+		j.err = errors.Wrap(err, "failed to empty log")
+		return j.err
+	}
+	if err := j.file.Sync(); err != nil {
+		j.err = errors.Wrap(err, "failed to sync log")
+		return j.err
+	}
+	j.dirty = false
+
+	return nil
+}
+
+// close syncs and closes the log. Later appends fail.
+func (j *journal[T]) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if errors.Is(j.err, errClosed) {
+		return nil
+	}
+
+	err := j.flushLocked()
+	if closeErr := j.file.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "failed to close log")
+	}
+	j.err = errClosed
+
+	return err
+}
+
+func writeFileSync(path string, contents []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "failed to create snapshot")
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, bytes.NewReader(contents)); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+
+	return errors.Wrap(f.Sync(), "failed to sync snapshot")
+}
+
+// syncDir makes renames in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to open store directory")
+	}
+	defer d.Close()
+
+	return errors.Wrap(d.Sync(), "failed to sync store directory")
+}
@@ -2,10 +2,10 @@ package memorystore
 
 import (
 	"context"
-	"pckilgore/app/pointers"
 	"pckilgore/app/store"
 	"sort"
-	"strings"
+
+	"github.com/pkg/errors"
 )
 
 type Lister[D store.Storable, P MemoryParams[D]] struct {
@@ -16,80 +16,345 @@ func NewLister[D store.Storable, P MemoryParams[D]](d *data[D]) *Lister[D, P] {
 	return &Lister[D, P]{d: d}
 }
 
-func (s *Lister[D, P]) List(_ context.Context, params P) (store.ListResponse[D], error) {
-	s.d.mu.RLock()
-	defer s.d.mu.RUnlock()
-	limit := params.Limit()
-
-	var after *string
-	var before *string
+func (s *Lister[D, P]) List(c context.Context, params P) (store.ListResponse[D], error) {
+	return s.list(c, params, nil)
+}
 
-	if params.After() != nil {
-		after = pointers.Make(params.After().Value())
+// list lists the records matching params, and among the IDs within returns in
+// order if within is not nil.
+func (s *Lister[D, P]) list(
+	c context.Context,
+	params P,
+	within func(v *version[D]) ([]string, error),
+) (store.ListResponse[D], error) {
+	v := s.d.read(c)
+	if params.After() != nil && params.Before() != nil {
+		return store.ListResponse[D]{}, errors.Wrap(
+			store.ErrInvalidPagination,
+			"only one of after or before can be set",
+		)
 	}
-	if params.Before() != nil {
-		before = pointers.Make(params.Before().Value())
+
+	sorter, err := newSorter[D](params)
+	if err != nil {
+		return store.ListResponse[D]{}, err
 	}
 
-	if after != nil && before != nil {
-		return store.ListResponse[D]{}, store.NewInvalidPaginationParamsErr(
-			"invalid parameters (only one of after or before can be set)",
-		)
+	// Candidates come from the indexes in ID order, which is the default sort.
+	var filter IndexFilter
+	if f, ok := any(params).(IndexFilterer); ok {
+		filter = f.IndexFilter()
+	}
+	ids, filtered, err := s.d.indexes.filter(v, filter)
+	if err != nil {
+		return store.ListResponse[D]{}, err
+	}
+	if within != nil {
+		in, err := within(v)
+		if err != nil {
+			return store.ListResponse[D]{}, err
+		}
+		if filtered {
+			ids = intersect(ids, in)
+		} else {
+			ids, filtered = in, true
+		}
 	}
 
-	var result []D
-	for _, m := range s.d.store {
-		result = append(result, m)
+	// Pages are walked to from the cursor, in ID order, or the order of the
+	// index of the first sort field when there is one and nothing narrower to
+	// start from. Otherwise, only the records listed are sorted.
+	byID := idWalker(v, ids, filtered)
+	walk, count := byID, -1
+	if len(sorter.sorts) > 0 {
+		if i, ok := s.d.indexes.fields[sorter.sorts[0].Field]; ok && !filtered {
+			walk = indexWalker(v, v.indexes[i], sorter)
+		} else {
+			sorted := s.sorted(params, sorter, byID)
+			walk, count = sliceWalker(sorted, sorter), len(sorted)
+		}
 	}
-	sort.SliceStable(result, func(i, j int) bool {
-		return result[i].GetID() < result[j].GetID()
+
+	return s.page(params, sorter, walk, byID, count)
+}
+
+// sorted returns the records params list in sort order, sorting them only once
+// filtered.
+func (s *Lister[D, P]) sorted(params P, sorter *sorter[D], byID walker[D]) []D {
+	var sorted []D
+	s.scan(params, byID, nil, false, func(m D) bool {
+		sorted = append(sorted, m)
+		return true
 	})
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorter.compare(sorter.keyset(sorted[i]), sorter.keyset(sorted[j])) < 0
+	})
+
+	return sorted
+}
 
-	result = params.MemoryFilter(result)
+// scanBatch is how many records lists pass to MemoryFilter at a time when
+// walking, which bounds how far past a page they read.
+const scanBatch = 64
 
-	startIndex := 0
-	endIndex := limit
-	if limit > len(result) {
-		endIndex = len(result)
+// page lists a page by walking from the cursor, rather than listing
+// everything. Only counting reads past the page, walking byID, unless count is
+// already known.
+func (s *Lister[D, P]) page(params P, sorter *sorter[D], walk walker[D], byID walker[D], count int) (store.ListResponse[D], error) {
+	limit := params.Limit()
+	backwards := params.Before() != nil
+	cursor := params.After()
+	if backwards {
+		cursor = params.Before()
 	}
 
-	if before != nil {
-		if newEnd, found := sort.Find(len(result), func(i int) int {
-			return strings.Compare(*before, result[i].GetID())
-		}); found {
-			endIndex = newEnd
-			if newEnd-limit > 0 {
-				startIndex = endIndex - limit
-			}
+	// Before the end is the last page, which is walked to from the end.
+	var from *keyset
+	if cursor != nil && !cursor.IsEnd() {
+		k, err := sorter.cursorKeyset(cursor)
+		if err != nil {
+			return store.ListResponse[D]{}, err
 		}
+		from = &k
 	}
 
-	if after != nil {
-		if newStart, found := sort.Find(len(result), func(i int) int {
-			return strings.Compare(*after, result[i].GetID())
-		}); found {
-			startIndex = newStart
-			endIndex = newStart + limit
-			if endIndex > len(result) {
-				endIndex = len(result)
-			}
+	// Take one more than the limit to learn whether there's another page, and
+	// look on the other side of the cursor for one there.
+	items := s.take(params, walk, from, backwards, true, limit+1)
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	behind := from != nil && len(items) > 0 && len(s.take(params, walk, from, !backwards, false, 1)) > 0
+	if backwards {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
 		}
 	}
 
-	var nextBefore *store.Cursor
-	if startIndex > 0 {
-		nextBefore = pointers.Make(store.NewCursor(result[startIndex].GetID()))
+	for i := range items {
+		items[i] = s.d.clone(items[i])
+	}
+	cursors, err := sorter.cursors(items)
+	if err != nil {
+		return store.ListResponse[D]{}, err
+	}
+
+	var nextBefore, nextAfter *store.Cursor
+	if len(items) > 0 {
+		first, last := &cursors[0], &cursors[len(cursors)-1]
+		if backwards {
+			more, behind = behind, more
+		}
+		if behind {
+			nextBefore = first
+		}
+		if more {
+			nextAfter = last
+		}
 	}
 
-	var nextAfter *store.Cursor
-	if endIndex < len(result) {
-		nextAfter = pointers.Make(store.NewCursor(result[endIndex].GetID()))
+	if params.CountMode() == store.CountNone {
+		count = -1
+	} else if count < 0 {
+		count = 0
+		s.scan(params, byID, nil, false, func(D) bool {
+			count++
+			return true
+		})
 	}
 
 	return store.ListResponse[D]{
-		Items:  result[startIndex:endIndex],
-		Count:  len(result),
-		After:  nextAfter,
-		Before: nextBefore,
+		Items:   items,
+		Cursors: cursors,
+		Count:   count,
+		After:   nextAfter,
+		Before:  nextBefore,
 	}, nil
 }
+
+// take returns up to n of the records params list, walking from from as scan
+// does, and leaving out the record at from if exclusive.
+func (s *Lister[D, P]) take(
+	params P,
+	walk walker[D],
+	from *keyset,
+	backwards bool,
+	exclusive bool,
+	n int,
+) []D {
+	var taken []D
+	s.scan(params, walk, from, backwards, func(m D) bool {
+		if exclusive && from != nil && m.GetID() == from.id {
+			return true
+		}
+		taken = append(taken, m)
+		return len(taken) < n
+	})
+
+	return taken
+}
+
+// scan calls fn with the records params list, walking as walk does, until fn
+// returns false. MemoryFilter keeps or drops each record on its own, so it is
+// applied to a batch at a time, and records it kept before keep it again.
+func (s *Lister[D, P]) scan(
+	params P,
+	walk walker[D],
+	from *keyset,
+	backwards bool,
+	fn func(m D) bool,
+) {
+	deleted := store.DeletedFilterOf(params)
+	var batch []D
+	done := false
+	flush := func() {
+		for _, m := range params.MemoryFilter(batch) {
+			if done = !fn(m); done {
+				break
+			}
+		}
+		batch = batch[:0]
+	}
+	walk(from, backwards, func(m D) bool {
+		if listed(m, deleted) {
+			batch = append(batch, m)
+		}
+		if len(batch) == scanBatch {
+			flush()
+		}
+		return !done
+	})
+	if !done && len(batch) > 0 {
+		flush()
+	}
+}
+
+// walker calls visit with records in list order from the first not before
+// from, or backwards from the last not after it, until visit returns false.
+// Without from, it starts from the start, or the end, of the list.
+type walker[D any] func(from *keyset, backwards bool, visit func(m D) bool)
+
+// idWalker walks the records of v in ID order, only those among ids if
+// filtered.
+func idWalker[D any](v *version[D], ids []string, filtered bool) walker[D] {
+	return func(from *keyset, backwards bool, visit func(m D) bool) {
+		var id *string
+		if from != nil {
+			id = &from.id
+		}
+
+		switch {
+		case !filtered && !backwards:
+			v.records.ascend(id, func(_ string, m D) bool { return visit(m) })
+		case !filtered:
+			v.records.descend(id, func(_ string, m D) bool { return visit(m) })
+		case !backwards:
+			i := 0
+			if id != nil {
+				i = sort.SearchStrings(ids, *id)
+			}
+			for ; i < len(ids); i++ {
+				if m, ok := v.get(ids[i]); ok && !visit(m) {
+					return
+				}
+			}
+		default:
+			i := len(ids) - 1
+			if id != nil {
+				i = sort.Search(len(ids), func(i int) bool { return ids[i] > *id }) - 1
+			}
+			for ; i >= 0; i-- {
+				if m, ok := v.get(ids[i]); ok && !visit(m) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// indexWalker walks the records of v in sort order, by e, the index of the
+// first sort field. Only runs of records sharing a value of that field are
+// sorted, by the rest of the sort.
+func indexWalker[D store.Storable](v *version[D], e entries, sorter *sorter[D]) walker[D] {
+	return func(from *keyset, backwards bool, visit func(m D) bool) {
+		// The index ascends by the first sort field, so walks it backwards to
+		// list in descending order.
+		ascending := sorter.sorts[0].Desc == backwards
+
+		var start *indexEntry
+		var fromKey any
+		if from != nil {
+			fromKey = from.values[0].Interface()
+			start = &indexEntry{key: fromKey, last: !ascending}
+		}
+
+		var run []D
+		var runKey any
+		flush := func() bool {
+			sort.Slice(run, func(i, j int) bool {
+				c := sorter.compare(sorter.keyset(run[i]), sorter.keyset(run[j]))
+				return (c < 0) != backwards
+			})
+			// Only the run the walk starts from can hold records before from.
+			skip := from != nil && compareKeys(runKey, fromKey) == 0
+			for _, m := range run {
+				if skip {
+					c := sorter.compare(sorter.keyset(m), *from)
+					if (c < 0) != backwards && c != 0 {
+						continue
+					}
+				}
+				if !visit(m) {
+					return false
+				}
+			}
+			run = run[:0]
+			return true
+		}
+
+		step := func(entry indexEntry, _ struct{}) bool {
+			if len(run) > 0 && compareKeys(entry.key, runKey) != 0 && !flush() {
+				return false
+			}
+			if m, ok := v.get(entry.id); ok {
+				run, runKey = append(run, m), entry.key
+			}
+			return true
+		}
+		if ascending {
+			e.ascend(start, step)
+		} else {
+			e.descend(start, step)
+		}
+		if len(run) > 0 {
+			flush()
+		}
+	}
+}
+
+// sliceWalker walks records already in sort order.
+func sliceWalker[D store.Storable](sorted []D, sorter *sorter[D]) walker[D] {
+	return func(from *keyset, backwards bool, visit func(m D) bool) {
+		if !backwards {
+			i := 0
+			if from != nil {
+				i = sort.Search(len(sorted), func(i int) bool {
+					return sorter.compare(sorter.keyset(sorted[i]), *from) >= 0
+				})
+			}
+			for ; i < len(sorted) && visit(sorted[i]); i++ {
+			}
+			return
+		}
+
+		i := len(sorted) - 1
+		if from != nil {
+			i = sort.Search(len(sorted), func(i int) bool {
+				return sorter.compare(sorter.keyset(sorted[i]), *from) > 0
+			}) - 1
+		}
+		for ; i >= 0 && visit(sorted[i]); i-- {
+		}
+	}
+}
@@ -1,4 +1,7 @@
 // Memorystore is a toy implementation of store using a in-memory map.
+//
+// Records are kept in immutable versions, copied on write, so reads see a
+// consistent point in time and never block writes. See [Store.Pin].
 package memorystore
 
 import (
@@ -11,7 +14,8 @@ import (
 type MemoryParams[D store.Storable] interface {
 	store.Parameterized
 
-	// MemoryFilter applies parameters to data.
+	// MemoryFilter applies parameters to data. It must keep or drop each
+	// record on its own, in order, since lists apply it a batch at a time.
 	MemoryFilter(pre []D) (post []D)
 }
 
@@ -28,37 +32,106 @@ func NewStore[D store.Storable, P MemoryParams[D]](d ...InitialData[D]) *Store[D
 		}
 	}
 
+	return newStore[D, P](data)
+}
+
+func newStore[D store.Storable, P MemoryParams[D]](data *data[D]) *Store[D, P] {
+	c := NewCreator(data)
+	d := NewDeleter(data)
 	return &Store[D, P]{
-		d: NewDeleter(data),
-		r: NewRetriever(data),
-		c: NewCreator(data),
-		l: NewLister[D, P](data),
+		data: data,
+		d:    d,
+		dm:   d,
+		dv:   d,
+		sd:   d,
+		r:    NewRetriever(data),
+		c:    c,
+		cm:   c,
+		u:    NewUpdater(data),
+		up:   NewUpserter(data),
+		l:    NewLister[D, P](data),
+		w:    NewWatcher(data),
 	}
 }
 
 type Store[D store.Storable, P MemoryParams[D]] struct {
-	d store.Deleter[D]
-	r store.Retriever[D]
-	c store.Creator[D]
-	l store.Lister[D, P]
+	data *data[D]
+
+	d  store.Deleter[D]
+	dm store.BatchDeleter[D]
+	dv store.VersionedDeleter
+	sd store.SoftDeleter
+	r  store.Retriever[D]
+	c  store.Creator[D]
+	cm store.BatchCreator[D]
+	u  store.Updater[D]
+	up store.Upserter[D]
+	l  store.Lister[D, P]
+	w  store.Watcher[D]
 }
 
 func (s *Store[D, P]) Create(c context.Context, m D) (*D, error) {
 	return s.c.Create(c, m)
 }
 
+func (s *Store[D, P]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	return s.cm.CreateMany(c, ms)
+}
+
 func (s *Store[D, P]) Retrieve(c context.Context, id string) (*D, bool, error) {
 	return s.r.Retrieve(c, id)
 }
 
+func (s *Store[D, P]) Update(c context.Context, m D) (*D, bool, error) {
+	return s.u.Update(c, m)
+}
+
+func (s *Store[D, P]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	return s.u.Patch(c, m, fields...)
+}
+
+func (s *Store[D, P]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	return s.up.Upsert(c, m, on)
+}
+
 func (s *Store[D, P]) Delete(c context.Context, id string) (bool, error) {
 	return s.d.Delete(c, id)
 }
 
+func (s *Store[D, P]) DeleteMany(c context.Context, ids []string) (int, error) {
+	return s.dm.DeleteMany(c, ids)
+}
+
+func (s *Store[D, P]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	return s.dv.DeleteVersion(c, id, version)
+}
+
+func (s *Store[D, P]) Restore(c context.Context, id string) (bool, error) {
+	return s.sd.Restore(c, id)
+}
+
+func (s *Store[D, P]) Purge(c context.Context, id string) (bool, error) {
+	return s.sd.Purge(c, id)
+}
+
 func (s *Store[D, P]) List(c context.Context, params P) (store.ListResponse[D], error) {
 	return s.l.List(c, params)
 }
 
+func (s *Store[D, P]) Watch(c context.Context, after store.ResumeToken, fn func(store.Change[D]) error) error {
+	return s.w.Watch(c, after, fn)
+}
+
+// Snapshot implements [Snapshotter].
+func (s *Store[D, P]) Snapshot(c context.Context) (context.Context, func(keep bool)) {
+	return s.data.snapshot(c)
+}
+
+// Pin implements [store.Pinner].
+func (s *Store[D, P]) Pin(c context.Context) (context.Context, func()) {
+	return s.data.pin(c)
+}
+
 func NewTreeStore[D store.TreeStorable, P MemoryParams[D]](d ...InitialData[D]) *TreeStore[D, P] {
 	var data *data[D]
 	if len(d) == 0 {
@@ -72,42 +145,120 @@ func NewTreeStore[D store.TreeStorable, P MemoryParams[D]](d ...InitialData[D])
 		}
 	}
 
+	return newTreeStore[D, P](data)
+}
+
+func newTreeStore[D store.TreeStorable, P MemoryParams[D]](data *data[D]) *TreeStore[D, P] {
 	return &TreeStore[D, P]{
-		store: &Store[D, P]{
-			d: NewDeleter(data),
-			r: NewRetriever(data),
-			c: NewCreator(data),
-			l: NewLister[D, P](data),
-		},
-		tree: NewTree(data),
+		data:  data,
+		store: newStore[D, P](data),
+		tree:  NewTree[D, P](data),
+		tl:    NewTreeLister[D, P](data),
 	}
 }
 
 type TreeStore[D store.TreeStorable, P MemoryParams[D]] struct {
-	store store.Store[D, P]
+	data *data[D]
+
+	store *Store[D, P]
 	tree  store.Tree[D]
+	tl    store.TreeLister[D, P]
 }
 
 func (s *TreeStore[D, P]) Create(c context.Context, m D) (*D, error) {
 	return s.store.Create(c, m)
 }
 
+func (s *TreeStore[D, P]) CreateMany(c context.Context, ms []D) ([]D, error) {
+	return s.store.CreateMany(c, ms)
+}
+
 func (s *TreeStore[D, P]) Retrieve(c context.Context, id string) (*D, bool, error) {
 	return s.store.Retrieve(c, id)
 }
 
+func (s *TreeStore[D, P]) Update(c context.Context, m D) (*D, bool, error) {
+	return s.store.Update(c, m)
+}
+
+func (s *TreeStore[D, P]) Patch(c context.Context, m D, fields ...string) (*D, bool, error) {
+	return s.store.Patch(c, m, fields...)
+}
+
+func (s *TreeStore[D, P]) Upsert(c context.Context, m D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	return s.store.Upsert(c, m, on)
+}
+
 func (s *TreeStore[D, P]) Delete(c context.Context, id string) (bool, error) {
 	return s.store.Delete(c, id)
 }
 
+func (s *TreeStore[D, P]) DeleteMany(c context.Context, ids []string) (int, error) {
+	return s.store.DeleteMany(c, ids)
+}
+
+func (s *TreeStore[D, P]) DeleteVersion(c context.Context, id string, version int64) (bool, error) {
+	return s.store.DeleteVersion(c, id, version)
+}
+
+func (s *TreeStore[D, P]) Restore(c context.Context, id string) (bool, error) {
+	return s.store.Restore(c, id)
+}
+
+func (s *TreeStore[D, P]) Purge(c context.Context, id string) (bool, error) {
+	return s.store.Purge(c, id)
+}
+
 func (s *TreeStore[D, P]) List(c context.Context, params P) (store.ListResponse[D], error) {
 	return s.store.List(c, params)
 }
 
-func (s *TreeStore[D, P]) ListDescendants(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	return s.tree.ListDescendants(c, rootId)
+func (s *TreeStore[D, P]) Watch(c context.Context, after store.ResumeToken, fn func(store.Change[D]) error) error {
+	return s.store.Watch(c, after, fn)
+}
+
+func (s *TreeStore[D, P]) ListDescendants(c context.Context, rootId string, o ...store.DescendantOptions) (store.TreeResponse[D], error) {
+	return s.tree.ListDescendants(c, rootId, o...)
+}
+
+func (s *TreeStore[D, P]) ListAncestors(c context.Context, rootId string, o ...store.AncestorOptions) (store.TreeResponse[D], error) {
+	return s.tree.ListAncestors(c, rootId, o...)
+}
+
+func (s *TreeStore[D, P]) Move(c context.Context, id string, parentID *string) (*D, bool, error) {
+	return s.tree.Move(c, id, parentID)
+}
+
+func (s *TreeStore[D, P]) LowestCommonAncestor(c context.Context, a, b string) (store.CommonAncestor[D], bool, error) {
+	return s.tree.LowestCommonAncestor(c, a, b)
+}
+
+func (s *TreeStore[D, P]) PathBetween(c context.Context, a, b string) (store.TreePath[D], bool, error) {
+	return s.tree.PathBetween(c, a, b)
+}
+
+func (s *TreeStore[D, P]) ListChildren(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return s.tl.ListChildren(c, id, params)
+}
+
+func (s *TreeStore[D, P]) ListSiblings(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return s.tl.ListSiblings(c, id, params)
+}
+
+func (s *TreeStore[D, P]) ListRoots(c context.Context, params P) (store.ListResponse[D], error) {
+	return s.tl.ListRoots(c, params)
+}
+
+func (s *TreeStore[D, P]) ListLeaves(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return s.tl.ListLeaves(c, id, params)
+}
+
+// Snapshot implements [Snapshotter].
+func (s *TreeStore[D, P]) Snapshot(c context.Context) (context.Context, func(keep bool)) {
+	return s.data.snapshot(c)
 }
 
-func (s *TreeStore[D, P]) ListAncestors(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	return s.tree.ListAncestors(c, rootId)
+// Pin implements [store.Pinner].
+func (s *TreeStore[D, P]) Pin(c context.Context) (context.Context, func()) {
+	return s.data.pin(c)
 }
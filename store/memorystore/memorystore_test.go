@@ -1,13 +1,20 @@
 package memorystore_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
 	"pckilgore/app/node"
 	"pckilgore/app/pointers"
 	"pckilgore/app/store"
+	"pckilgore/app/store/gormstore"
 	"pckilgore/app/store/memorystore"
 	"pckilgore/app/store/pagination"
 	storetest "pckilgore/app/store/test"
@@ -15,30 +22,74 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// newTestStore returns an empty tree store of nodes for a test.
+func newTestStore(t *testing.T) *memorystore.TreeStore[node.DatabaseNode, node.NodeParams] {
+	t.Helper()
+	return memorystore.NewTreeStore[node.DatabaseNode, node.NodeParams]()
+}
+
+// buildNode builds a root node with an ID unique to nonce.
+func buildNode(nonce int) node.DatabaseNode {
+	return buildTreeNode(nonce, nil)
+}
+
+// buildTreeNode builds a node under parentID with an ID unique to nonce.
+func buildTreeNode(nonce int, parentID *string) node.DatabaseNode {
+	return node.DatabaseNode{
+		ID:       fmt.Sprintf("%03d", nonce),
+		Name:     fmt.Sprintf("testing node %d", nonce),
+		ParentID: parentID,
+	}
+}
+
+// renameNode changes the name of a node.
+func renameNode(model node.DatabaseNode) (node.DatabaseNode, []string) {
+	model.Name = model.Name + " (updated)"
+	return model, []string{"Name"}
+}
+
+func nodeParams(p pagination.Params) node.NodeParams {
+	return node.NodeParams{Pagination: pagination.MustNew(p)}
+}
+
 func TestMemoryTreeStore(t *testing.T) {
 	t.Parallel()
 
-	nodeStore := memorystore.NewTreeStore[node.DatabaseNode, node.NodeParams]()
+	testTreeStore(t, newTestStore(t))
+}
 
+func TestDurableTreeStore(t *testing.T) {
+	t.Parallel()
+
+	for _, sync := range []memorystore.SyncPolicy{memorystore.SyncAlways, memorystore.SyncPeriodic, memorystore.SyncNever} {
+		sync := sync
+		t.Run(fmt.Sprintf("sync policy %d", sync), func(t *testing.T) {
+			t.Parallel()
+
+			nodeStore, err := memorystore.OpenTreeStore[node.DatabaseNode, node.NodeParams](
+				t.TempDir(),
+				memorystore.DurableOptions{Sync: sync, SyncInterval: time.Millisecond, CompactInterval: 10 * time.Millisecond},
+			)
+			require.Nil(t, err)
+			defer nodeStore.Close()
+
+			testTreeStore(t, nodeStore)
+		})
+	}
+}
+
+func testTreeStore(t *testing.T, nodeStore store.TreeStore[node.DatabaseNode, node.NodeParams]) {
 	storetest.CreateTreeStoreTest[node.DatabaseNode, node.NodeParams](
 		t,
 		nodeStore,
-		func(nonce int, parentId *string) node.DatabaseNode {
-			return node.DatabaseNode{
-				ID:       fmt.Sprintf("%03d", nonce),
-				Name:     fmt.Sprintf("testing node %d", nonce),
-				ParentID: parentId,
-			}
-		},
+		buildTreeNode,
 		func(t *testing.T, model node.DatabaseNode) {
 			require.NotNil(t, model.ID)
 			require.Contains(t, model.Name, "testing node")
 		},
-		func(limit int, after *store.Cursor, before *store.Cursor) node.NodeParams {
-			return node.NodeParams{
-				Pagination: pagination.New(pagination.Params{Limit: limit, After: after, Before: before}),
-			}
-		},
+		renameNode,
+		nodeParams,
+		[]string{"Name"},
 		func(d []node.DatabaseNode) node.NodeParams {
 			var ids []node.ID
 			for _, item := range d {
@@ -54,7 +105,7 @@ func TestMemoryTreeStore(t *testing.T) {
 
 			return node.NodeParams{
 				IDs:        pointers.Make(ids),
-				Pagination: pagination.New(pagination.Params{}),
+				Pagination: pagination.MustNew(pagination.Params{}),
 			}
 		},
 		func(t *testing.T, params node.NodeParams, d []node.DatabaseNode) {
@@ -68,5 +119,706 @@ func TestMemoryTreeStore(t *testing.T) {
 			require.Equal(t, len(d), len(*params.IDs))
 			require.Subset(t, gotIds, *params.IDs)
 		},
+		func(d []node.DatabaseNode) node.NodeParams {
+			var ids []node.ID
+			for _, item := range d {
+				w, err := node.Deserialize(&item)
+				require.Nil(t, err)
+				ids = append(ids, node.ID(w.ID))
+			}
+
+			return node.NodeParams{
+				IDs:        pointers.Make(ids),
+				Pagination: pagination.MustNew(pagination.Params{}),
+			}
+		},
+	)
+}
+
+func TestMemoryTransactor(t *testing.T) {
+	t.Parallel()
+
+	nodeStore := newTestStore(t)
+
+	storetest.CreateTransactorTest[node.DatabaseNode, node.NodeParams](
+		t,
+		memorystore.NewTransactor(nodeStore),
+		nodeStore,
+		buildNode,
+		renameNode,
+	)
+
+	t.Run("outside writes", func(t *testing.T) {
+		ctx := context.Background()
+		outside := make(chan error, 1)
+		err := memorystore.NewTransactor(nodeStore).RunInTx(ctx, func(c context.Context) error {
+			_, err := nodeStore.Create(c, buildNode(1000))
+			require.Nil(t, err)
+
+			go func() {
+				_, err := nodeStore.Create(ctx, buildNode(1001))
+				outside <- err
+			}()
+			// Give the outside write time to run, were it not held off.
+			time.Sleep(10 * time.Millisecond)
+
+			return errors.New("rollback")
+		})
+		require.NotNil(t, err)
+		require.Nil(t, <-outside)
+
+		_, found, err := nodeStore.Retrieve(ctx, buildNode(1000).ID)
+		require.Nil(t, err)
+		require.False(t, found, "writes in the transaction should be rolled back")
+
+		_, found, err = nodeStore.Retrieve(ctx, buildNode(1001).ID)
+		require.Nil(t, err)
+		require.True(t, found, "writes outside the transaction should not be rolled back")
+	})
+}
+
+func TestMemoryBatch(t *testing.T) {
+	t.Parallel()
+
+	storetest.CreateBatchTest[node.DatabaseNode, node.NodeParams](t, newTestStore(t), buildNode)
+}
+
+func TestMemoryUpsert(t *testing.T) {
+	t.Parallel()
+
+	storetest.CreateUpsertTest[node.DatabaseNode, node.NodeParams](t, newTestStore(t), buildNode, renameNode)
+}
+
+func TestMemoryVersioned(t *testing.T) {
+	t.Parallel()
+
+	storetest.CreateVersionedTest[storetest.VersionedModel, storetest.VersionedParams](
+		t,
+		memorystore.NewStore[storetest.VersionedModel, storetest.VersionedParams](),
+		storetest.NewVersionedModel,
+		storetest.RenameVersionedModel,
+	)
+}
+
+func TestMemorySoftDelete(t *testing.T) {
+	t.Parallel()
+
+	storetest.CreateSoftDeleteTest[storetest.DeletableModel, storetest.DeletableParams](
+		t,
+		memorystore.NewStore[storetest.DeletableModel, storetest.DeletableParams](),
+		storetest.NewDeletableModel,
+		func(p pagination.Params, deleted store.DeletedFilter) storetest.DeletableParams {
+			return storetest.DeletableParams{Deleted: deleted, Pagination: pagination.MustNew(p)}
+		},
+	)
+}
+
+func TestDurableStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func() *memorystore.DurableStore[storetest.DeletableModel, storetest.DeletableParams] {
+		s, err := memorystore.OpenStore[storetest.DeletableModel, storetest.DeletableParams](dir)
+		require.Nil(t, err)
+		return s
+	}
+	list := func(s *memorystore.DurableStore[storetest.DeletableModel, storetest.DeletableParams]) []storetest.DeletableModel {
+		list, err := s.List(ctx, storetest.DeletableParams{
+			Deleted:    store.IncludeDeleted,
+			Pagination: pagination.MustNew(pagination.Params{}),
+		})
+		require.Nil(t, err)
+		return list.Items
+	}
+
+	_, err := memorystore.OpenStore[storetest.DeletableModel, storetest.DeletableParams](
+		dir, memorystore.DurableOptions{}, memorystore.DurableOptions{},
 	)
+	require.ErrorIs(t, err, store.ErrInvalidInput, "only one set of options can be passed")
+
+	s := open()
+	var ms []storetest.DeletableModel
+	for i := 0; i < 10; i++ {
+		ms = append(ms, storetest.DeletableModel{ID: fmt.Sprintf("%03d", i), Name: fmt.Sprintf("model %d", i)})
+	}
+	_, err = s.CreateMany(ctx, ms)
+	require.Nil(t, err)
+	_, _, err = s.Patch(ctx, storetest.DeletableModel{ID: "001", Name: "patched", Version: 1}, "Name")
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, "002")
+	require.Nil(t, err)
+	_, err = s.Purge(ctx, "003")
+	require.Nil(t, err)
+	want := list(s)
+	require.Len(t, want, 9)
+
+	t.Run("replay", func(t *testing.T) {
+		require.Nil(t, s.Close())
+		_, err := s.Create(ctx, storetest.DeletableModel{ID: "closed"})
+		require.NotNil(t, err, "closed stores should not accept writes")
+
+		s = open()
+		require.Equal(t, want, list(s), "reopening should replay every write")
+	})
+
+	t.Run("compaction", func(t *testing.T) {
+		require.Nil(t, s.Compact())
+		info, err := os.Stat(filepath.Join(dir, "wal.jsonl"))
+		require.Nil(t, err)
+		require.Zero(t, info.Size(), "compaction should empty the log")
+
+		_, err = s.Delete(ctx, "004")
+		require.Nil(t, err)
+		want = list(s)
+
+		require.Nil(t, s.Close())
+		s = open()
+		require.Equal(t, want, list(s), "reopening should replay the log over the snapshot")
+	})
+
+	t.Run("torn write", func(t *testing.T) {
+		require.Nil(t, s.Close())
+
+		// A crash mid-append leaves a partial final line.
+		f, err := os.OpenFile(filepath.Join(dir, "wal.jsonl"), os.O_WRONLY|os.O_APPEND, 0o644)
+		require.Nil(t, err)
+		_, err = f.WriteString(`[{"id":"torn","value":{"ID":"to`)
+		require.Nil(t, err)
+		require.Nil(t, f.Close())
+
+		s = open()
+		require.Equal(t, want, list(s), "a partial final entry should be dropped")
+
+		_, err = s.Create(ctx, storetest.DeletableModel{ID: "after"})
+		require.Nil(t, err)
+		require.Nil(t, s.Close())
+		s = open()
+		_, found, err := s.Retrieve(ctx, "after")
+		require.Nil(t, err)
+		require.True(t, found, "writes after a partial entry should replay")
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		tx := memorystore.NewTransactor(s)
+		err := tx.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := s.Create(ctx, storetest.DeletableModel{ID: "rolled back"})
+			require.Nil(t, err)
+			_, err = s.Purge(ctx, "005")
+			require.Nil(t, err)
+			return errors.New("rollback")
+		})
+		require.NotNil(t, err)
+		want = list(s)
+
+		require.Nil(t, s.Close())
+		s = open()
+		require.Equal(t, want, list(s), "rollbacks should be persisted")
+		_, found, err := s.Retrieve(ctx, "rolled back")
+		require.Nil(t, err)
+		require.False(t, found)
+	})
+
+	require.Nil(t, s.Close())
+}
+
+// renamedParams filters by a field nodes do not index.
+type renamedParams struct {
+	node.NodeParams
+}
+
+func (renamedParams) IndexFilter() memorystore.IndexFilter {
+	return memorystore.IndexFilter{"ID": {"renamed"}}
+}
+
+func TestMemoryIndexes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	nodeStore := newTestStore(t)
+	byParent := func(parentIDs ...node.ID) node.NodeParams {
+		return node.NodeParams{ParentIDs: &parentIDs, Pagination: pagination.MustNew(pagination.Params{})}
+	}
+	ids := func(list store.ListResponse[node.DatabaseNode]) []string {
+		var ids []string
+		for _, item := range list.Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	// Check every filter against a scan of every node.
+	rng := rand.New(rand.NewSource(1))
+	var all []string
+	check := func(t *testing.T) {
+		t.Helper()
+		var everything []node.DatabaseNode
+		err := store.Iterate[node.DatabaseNode, node.NodeParams](ctx, nodeStore,
+			func(after *store.Cursor) node.NodeParams {
+				return node.NodeParams{Pagination: pagination.MustNew(pagination.Params{After: after})}
+			},
+			func(page []node.DatabaseNode) error {
+				everything = append(everything, page...)
+				return nil
+			},
+		)
+		require.Nil(t, err)
+
+		for _, parentID := range append([]string{gormstore.Null}, all...) {
+			var want []string
+			for _, item := range everything {
+				if (item.ParentID == nil && parentID == gormstore.Null) || (item.ParentID != nil && *item.ParentID == parentID) {
+					want = append(want, item.ID)
+				}
+			}
+
+			got, err := nodeStore.List(ctx, byParent(node.ID(parentID)))
+			require.Nil(t, err)
+			require.Equal(t, want, ids(got), "children of %s should be served from the index", parentID)
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("%03d", i)
+		var parentID *string
+		if len(all) > 0 && rng.Intn(4) > 0 {
+			parentID = &all[rng.Intn(len(all))]
+		}
+		_, err := nodeStore.Create(ctx, node.DatabaseNode{ID: id, ParentID: parentID})
+		require.Nil(t, err)
+		all = append(all, id)
+	}
+	check(t)
+
+	t.Run("writes", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			moved := node.DatabaseNode{ID: all[rng.Intn(len(all))], ParentID: pointers.Make(all[rng.Intn(len(all))])}
+			_, _, err := nodeStore.Patch(ctx, moved, "ParentID")
+			require.Nil(t, err)
+		}
+		_, err := nodeStore.DeleteMany(ctx, all[:20])
+		require.Nil(t, err)
+		_, _, err = nodeStore.Upsert(ctx, node.DatabaseNode{ID: all[0]}, store.OnConflict{Strategy: store.ConflictOverwrite})
+		require.Nil(t, err)
+		check(t)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		err := memorystore.NewTransactor(nodeStore).RunInTx(ctx, func(ctx context.Context) error {
+			_, err := nodeStore.Create(ctx, node.DatabaseNode{ID: "rolled back", ParentID: &all[30]})
+			require.Nil(t, err)
+			_, _, err = nodeStore.Patch(ctx, node.DatabaseNode{ID: all[31]}, "ParentID")
+			require.Nil(t, err)
+			_, err = nodeStore.Delete(ctx, all[32])
+			require.Nil(t, err)
+			return errors.New("rollback")
+		})
+		require.NotNil(t, err)
+		check(t)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		var roots []string
+		params := byParent(node.ID(gormstore.Null))
+		params.Pagination = pagination.MustNew(pagination.Params{Limit: 3})
+		for {
+			page, err := nodeStore.List(ctx, params)
+			require.Nil(t, err)
+			roots = append(roots, ids(page)...)
+			if page.After == nil {
+				break
+			}
+			params.Pagination = pagination.MustNew(pagination.Params{Limit: 3, After: page.After})
+		}
+
+		want, err := nodeStore.List(ctx, byParent(node.ID(gormstore.Null)))
+		require.Nil(t, err)
+		require.Equal(t, ids(want), roots)
+	})
+
+	t.Run("many values", func(t *testing.T) {
+		a, err := nodeStore.List(ctx, byParent(node.ID(all[40])))
+		require.Nil(t, err)
+		b, err := nodeStore.List(ctx, byParent(node.ID(all[41])))
+		require.Nil(t, err)
+		both, err := nodeStore.List(ctx, byParent(node.ID(all[41]), node.ID(all[40])))
+		require.Nil(t, err)
+		require.ElementsMatch(t, append(ids(a), ids(b)...), ids(both))
+		require.IsIncreasing(t, ids(both), "lists should stay in ID order")
+	})
+
+	t.Run("unindexed", func(t *testing.T) {
+		renamed := memorystore.NewStore[node.DatabaseNode, renamedParams]()
+		_, err := renamed.List(ctx, renamedParams{node.NodeParams{Pagination: pagination.MustNew(pagination.Params{})}})
+		require.ErrorIs(t, err, store.ErrInvalidInput, "unindexed fields cannot be filtered on")
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		// Names repeat, so pages split runs of equal names.
+		sorted := newTestStore(t)
+		var nodes []node.DatabaseNode
+		var even []node.ID
+		for _, i := range rng.Perm(60) {
+			n := node.DatabaseNode{ID: fmt.Sprintf("%03d", i), Name: fmt.Sprintf("name %d", i%7)}
+			_, err := sorted.Create(ctx, n)
+			require.Nil(t, err)
+			nodes = append(nodes, n)
+			if i%2 == 0 {
+				even = append(even, node.ID(n.ID))
+			}
+		}
+
+		for _, desc := range []bool{false, true} {
+			sort.Slice(nodes, func(i, j int) bool {
+				if nodes[i].Name != nodes[j].Name {
+					return (nodes[i].Name < nodes[j].Name) != desc
+				}
+				return nodes[i].ID < nodes[j].ID
+			})
+			var want, wantEven []string
+			for _, n := range nodes {
+				want = append(want, n.ID)
+				if n.ID[len(n.ID)-1]%2 == 0 {
+					wantEven = append(wantEven, n.ID)
+				}
+			}
+
+			page := func(ids *[]node.ID, after, before *store.Cursor) store.ListResponse[node.DatabaseNode] {
+				list, err := sorted.List(ctx, node.NodeParams{IDs: ids, Pagination: pagination.MustNew(pagination.Params{
+					Limit:  4,
+					After:  after,
+					Before: before,
+					Sort:   []store.Sort{{Field: "Name", Desc: desc}},
+				})})
+				require.Nil(t, err)
+				return list
+			}
+
+			var forwards []string
+			for list := page(nil, nil, nil); ; list = page(nil, list.After, nil) {
+				forwards = append(forwards, ids(list)...)
+				if list.After == nil {
+					break
+				}
+			}
+			require.Equal(t, want, forwards, "paging forwards through the index, descending %t", desc)
+
+			var backwards []string
+			end := store.EndCursor()
+			for list := page(nil, nil, &end); ; list = page(nil, nil, list.Before) {
+				backwards = append(ids(list), backwards...)
+				if list.Before == nil {
+					break
+				}
+			}
+			require.Equal(t, want, backwards, "paging backwards through the index, descending %t", desc)
+
+			var filtered []string
+			for list := page(&even, nil, nil); ; list = page(&even, list.After, nil) {
+				require.Equal(t, len(wantEven), list.Count)
+				filtered = append(filtered, ids(list)...)
+				if list.After == nil {
+					break
+				}
+			}
+			require.Equal(t, wantEven, filtered, "filtering pages through the index, descending %t", desc)
+		}
+	})
+}
+
+const benchmarkSeedCount = 10000
+
+// seedTree creates a tree of benchmarkSeedCount nodes, ten children to each
+// parent, returning the ID of its root.
+func seedTree(b *testing.B) (*memorystore.TreeStore[node.DatabaseNode, node.NodeParams], string) {
+	b.Helper()
+
+	nodes := make([]node.DatabaseNode, benchmarkSeedCount)
+	for i := range nodes {
+		nodes[i] = node.DatabaseNode{ID: fmt.Sprintf("%05d", i), Name: fmt.Sprintf("node %d", i)}
+		if i > 0 {
+			nodes[i].ParentID = &nodes[(i-1)/10].ID
+		}
+	}
+
+	nodeStore := memorystore.NewTreeStore[node.DatabaseNode, node.NodeParams]()
+	_, err := nodeStore.CreateMany(context.Background(), nodes)
+	require.Nil(b, err)
+
+	return nodeStore, nodes[0].ID
+}
+
+func BenchmarkMemoryList(b *testing.B) {
+	nodeStore, _ := seedTree(b)
+	params := node.NodeParams{Pagination: pagination.MustNew(pagination.Params{Count: pointers.Make(store.CountNone)})}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := nodeStore.List(context.Background(), params)
+		require.Nil(b, err)
+	}
+}
+
+func BenchmarkMemoryListByParent(b *testing.B) {
+	nodeStore, root := seedTree(b)
+	params := node.NodeParams{
+		ParentIDs:  &[]node.ID{node.ID(root)},
+		Pagination: pagination.MustNew(pagination.Params{}),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := nodeStore.List(context.Background(), params)
+		require.Nil(b, err)
+	}
+}
+
+func BenchmarkMemoryListSorted(b *testing.B) {
+	nodeStore, _ := seedTree(b)
+	params := node.NodeParams{Pagination: pagination.MustNew(pagination.Params{
+		Sort:  []store.Sort{{Field: "Name", Desc: true}},
+		Count: pointers.Make(store.CountNone),
+	})}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := nodeStore.List(context.Background(), params)
+		require.Nil(b, err)
+	}
+}
+
+func BenchmarkMemoryListSortedFiltered(b *testing.B) {
+	nodeStore, _ := seedTree(b)
+	// Every tenth node, by a filter no index serves.
+	var ids []node.ID
+	for i := 0; i < benchmarkSeedCount; i += 10 {
+		ids = append(ids, node.ID(fmt.Sprintf("%05d", i)))
+	}
+	params := node.NodeParams{IDs: &ids, Pagination: pagination.MustNew(pagination.Params{
+		Limit: 10,
+		Sort:  []store.Sort{{Field: "Name"}},
+		Count: pointers.Make(store.CountNone),
+	})}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := nodeStore.List(context.Background(), params)
+		require.Nil(b, err)
+	}
+}
+
+func BenchmarkMemoryListDescendants(b *testing.B) {
+	nodeStore, _ := seedTree(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// A subtree of a thousand nodes.
+		_, err := nodeStore.ListDescendants(context.Background(), "00001")
+		require.Nil(b, err)
+	}
+}
+
+func BenchmarkMemoryCreate(b *testing.B) {
+	nodeStore, root := seedTree(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := nodeStore.Create(context.Background(), node.DatabaseNode{ID: fmt.Sprintf("new %d", i), ParentID: &root})
+		require.Nil(b, err)
+	}
+}
+
+func TestMemoryPin(t *testing.T) {
+	t.Parallel()
+
+	nodeStore := newTestStore(t)
+	storetest.CreatePinTest[node.DatabaseNode, node.NodeParams](t, nodeStore, buildNode, renameNode, nodeParams)
+
+	t.Run("trees", func(t *testing.T) {
+		ctx := context.Background()
+		root, err := nodeStore.Create(ctx, node.DatabaseNode{ID: "pinned root"})
+		require.Nil(t, err)
+
+		pinned, release := nodeStore.Pin(ctx)
+		defer release()
+		_, err = nodeStore.Create(ctx, node.DatabaseNode{ID: "pinned child", ParentID: &root.ID})
+		require.Nil(t, err)
+
+		tree, err := nodeStore.ListDescendants(pinned, root.ID)
+		require.Nil(t, err)
+		require.Equal(t, 1, tree.Count, "pinned tree reads should not see later writes")
+		tree, err = nodeStore.ListDescendants(ctx, root.ID)
+		require.Nil(t, err)
+		require.Equal(t, 2, tree.Count)
+	})
+}
+
+// taggedModel has references of every kind, and copies them itself.
+type taggedModel struct {
+	ID     string
+	Tags   []string
+	Labels map[string]*string
+}
+
+func (taggedModel) TableName() string {
+	return "tagged_models"
+}
+
+func (m taggedModel) GetID() string {
+	return m.ID
+}
+
+func (taggedModel) NewID() string {
+	return fmt.Sprintf("%d", rand.Int())
+}
+
+func (m taggedModel) Clone() taggedModel {
+	m.Tags = append([]string(nil), m.Tags...)
+	labels := make(map[string]*string, len(m.Labels))
+	for k, v := range m.Labels {
+		labels[k] = pointers.Make(*v)
+	}
+	m.Labels = labels
+	return m
+}
+
+type taggedParams struct {
+	pagination.Pagination
+}
+
+func (taggedParams) MemoryFilter(in []taggedModel) []taggedModel {
+	return in
+}
+
+func TestMemoryIsolation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reflection", func(t *testing.T) {
+		storetest.CreateIsolationTest[node.DatabaseNode, node.NodeParams](
+			t,
+			newTestStore(t),
+			func(nonce int) node.DatabaseNode {
+				return buildTreeNode(nonce, pointers.Make(fmt.Sprintf("parent of %03d", nonce)))
+			},
+			nodeParams,
+		)
+	})
+
+	t.Run("cloner", func(t *testing.T) {
+		storetest.CreateIsolationTest[taggedModel, taggedParams](
+			t,
+			memorystore.NewStore[taggedModel, taggedParams](),
+			func(nonce int) taggedModel {
+				return taggedModel{
+					ID:     fmt.Sprintf("%03d", nonce),
+					Tags:   []string{"a", "b"},
+					Labels: map[string]*string{"c": pointers.Make("d")},
+				}
+			},
+			func(p pagination.Params) taggedParams {
+				return taggedParams{Pagination: pagination.MustNew(p)}
+			},
+		)
+	})
+
+	t.Run("trees", func(t *testing.T) {
+		ctx := context.Background()
+		nodeStore := newTestStore(t)
+		root, err := nodeStore.Create(ctx, node.DatabaseNode{ID: "root"})
+		require.Nil(t, err)
+		_, err = nodeStore.Create(ctx, node.DatabaseNode{ID: "child", ParentID: pointers.Make(root.ID)})
+		require.Nil(t, err)
+
+		tree, err := nodeStore.ListAncestors(ctx, "child")
+		require.Nil(t, err)
+		*tree.Layers[0].Items[0].ParentID = "scribbled"
+		tree, err = nodeStore.ListDescendants(ctx, root.ID)
+		require.Nil(t, err)
+		*tree.Layers[1].Items[0].ParentID = "scribbled"
+
+		child, _, err := nodeStore.Retrieve(ctx, "child")
+		require.Nil(t, err)
+		require.Equal(t, root.ID, *child.ParentID, "changing tree results should not change the store")
+	})
+}
+
+func TestMemoryWatch(t *testing.T) {
+	t.Parallel()
+
+	storetest.CreateWatchTest[node.DatabaseNode, node.NodeParams](t, newTestStore(t), buildNode, renameNode)
+
+	t.Run("expired", func(t *testing.T) {
+		ctx := context.Background()
+		s := memorystore.NewStore[node.DatabaseNode, node.NodeParams]()
+		for i := 0; i < 3*4096; i++ {
+			_, err := s.Create(ctx, node.DatabaseNode{ID: fmt.Sprintf("expired %05d", i)})
+			require.Nil(t, err)
+		}
+
+		err := s.Watch(ctx, store.NewResumeToken(1), func(store.Change[node.DatabaseNode]) error {
+			return nil
+		})
+		require.ErrorIs(t, err, store.ErrTokenExpired)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+		open := func() *memorystore.DurableStore[node.DatabaseNode, node.NodeParams] {
+			s, err := memorystore.OpenStore[node.DatabaseNode, node.NodeParams](dir)
+			require.Nil(t, err)
+			return s
+		}
+		create := func(s *memorystore.DurableStore[node.DatabaseNode, node.NodeParams], ids ...string) {
+			for _, id := range ids {
+				_, err := s.Create(ctx, node.DatabaseNode{ID: id})
+				require.Nil(t, err)
+			}
+		}
+		// watch returns the IDs of the changes after a token, up to the latest.
+		watch := func(s *memorystore.DurableStore[node.DatabaseNode, node.NodeParams], after store.ResumeToken) ([]string, store.ResumeToken, error) {
+			ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+
+			var ids []string
+			err := s.Watch(ctx, after, func(change store.Change[node.DatabaseNode]) error {
+				ids = append(ids, change.ID)
+				after = change.Token()
+				return nil
+			})
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = nil
+			}
+			return ids, after, err
+		}
+
+		s := open()
+		create(s, "a", "b", "c")
+		_, stale, err := watch(s, "")
+		require.Nil(t, err)
+		create(s, "d")
+		ids, token, err := watch(s, stale)
+		require.Nil(t, err)
+		require.Equal(t, []string{"d"}, ids)
+		require.Nil(t, s.Close())
+
+		// Numbering continues, so more writes than before cannot make a token
+		// from before the restart point at the wrong change.
+		s = open()
+		create(s, "e", "f", "g", "h", "i")
+		ids, token, err = watch(s, token)
+		require.Nil(t, err)
+		require.Equal(t, []string{"e", "f", "g", "h", "i"}, ids, "should resume after a restart")
+		_, _, err = watch(s, stale)
+		require.ErrorIs(t, err, store.ErrTokenExpired, "changes missed before a restart are not kept")
+
+		require.Nil(t, s.Compact())
+		require.Nil(t, s.Close())
+		s = open()
+		create(s, "j")
+		ids, _, err = watch(s, token)
+		require.Nil(t, err)
+		require.Equal(t, []string{"j"}, ids, "should resume after compacting")
+		require.Nil(t, s.Close())
+	})
 }
@@ -14,11 +14,9 @@ func NewRetriever[D store.Storable](d *data[D]) *Retriever[D] {
 	return &Retriever[D]{d: d}
 }
 
-func (r *Retriever[D]) Retrieve(_ context.Context, id string) (*D, bool, error) {
-	r.d.mu.RLock()
-	defer r.d.mu.RUnlock()
-
-	if model, exists := r.d.store[id]; exists {
+func (r *Retriever[D]) Retrieve(c context.Context, id string) (*D, bool, error) {
+	if model, exists := r.d.read(c).get(id); exists {
+		model = r.d.clone(model)
 		return &model, true, nil
 	}
 
@@ -0,0 +1,54 @@
+package memorystore
+
+import (
+	"time"
+
+	"pckilgore/app/store"
+)
+
+// listed reports whether m is included in lists under filter.
+func listed[D any](m D, filter store.DeletedFilter) bool {
+	soft, ok := any(m).(store.SoftDeletable[D])
+	if !ok {
+		return filter != store.OnlyDeleted
+	}
+
+	switch filter {
+	case store.IncludeDeleted:
+		return true
+	case store.OnlyDeleted:
+		return soft.GetDeletedAt() != nil
+	}
+
+	return soft.GetDeletedAt() == nil
+}
+
+// removal returns the write that deletes the record with id, or marks it
+// deleted at if it is soft deletable, or false if there is no live record to
+// remove. d.mu must be held.
+func (d *data[T]) removal(id string, at time.Time) (write[T], bool) {
+	existing, exists := d.get(id)
+	if !exists {
+		return write[T]{}, false
+	}
+
+	soft, ok := any(existing).(store.SoftDeletable[T])
+	if !ok {
+		return unset[T](id), true
+	} else if soft.GetDeletedAt() != nil {
+		return write[T]{}, false
+	}
+
+	return set(id, bumpVersion(existing, soft.WithDeletedAt(&at))), true
+}
+
+// remove applies the removal of the record with id, reporting whether there
+// was a live record to remove. d.mu must be held.
+func (d *data[T]) remove(id string, at time.Time) (bool, error) {
+	w, ok := d.removal(id, at)
+	if !ok {
+		return false, nil
+	}
+
+	return true, d.apply(w)
+}
@@ -0,0 +1,183 @@
+package memorystore
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"pckilgore/app/store"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// keyset is the position of a record in a sorted list: the values of its sort
+// fields, then its ID as a tiebreaker.
+type keyset struct {
+	values []reflect.Value
+	id     string
+}
+
+// sorter orders records of D by the sort of a query, and converts between
+// records and cursors bound to that query.
+type sorter[D store.Storable] struct {
+	sorts       []store.Sort
+	fields      []reflect.StructField
+	fingerprint string
+}
+
+func newSorter[D store.Storable](params store.Parameterized) (*sorter[D], error) {
+	t := reflect.TypeOf(*new(D))
+	sorts := params.Sort()
+
+	fingerprint, err := store.Fingerprint(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []reflect.StructField
+	for _, s := range sorts {
+		field, ok := t.FieldByName(s.Field)
+		if !ok || !field.IsExported() {
+			return nil, errors.Wrapf(store.ErrInvalidPagination, "cannot sort by unknown field %q", s.Field)
+		} else if !sortable(field.Type) {
+			return nil, errors.Wrapf(store.ErrInvalidPagination, "cannot sort by field %q of type %s", s.Field, field.Type)
+		}
+		fields = append(fields, field)
+	}
+
+	return &sorter[D]{sorts: sorts, fields: fields, fingerprint: fingerprint}, nil
+}
+
+func (s *sorter[D]) keyset(m D) keyset {
+	v := reflect.ValueOf(m)
+	k := keyset{id: m.GetID()}
+	for _, field := range s.fields {
+		k.values = append(k.values, v.FieldByIndex(field.Index))
+	}
+
+	return k
+}
+
+// cursorKeyset decodes the position a cursor points to.
+func (s *sorter[D]) cursorKeyset(c *store.Cursor) (keyset, error) {
+	if err := c.Verify(s.fingerprint); err != nil {
+		return keyset{}, err
+	}
+
+	keys := c.Keys()
+	if len(keys) != len(s.fields) {
+		return keyset{}, errors.Wrap(store.ErrCursorMismatch, "wrong number of sort keys")
+	}
+
+	k := keyset{id: c.Value()}
+	for i, field := range s.fields {
+		v := reflect.New(field.Type)
+		if err := json.Unmarshal(keys[i], v.Interface()); err != nil {
+			return keyset{}, errors.Wrapf(store.ErrCursorMismatch, "bad sort key: %s", err)
+		}
+		k.values = append(k.values, v.Elem())
+	}
+
+	return k, nil
+}
+
+// cursors returns a cursor pointing at each of items.
+func (s *sorter[D]) cursors(items []D) ([]store.Cursor, error) {
+	var cursors []store.Cursor
+	for _, item := range items {
+		c, err := s.cursor(item)
+		if err != nil {
+			return nil, err
+		}
+		cursors = append(cursors, *c)
+	}
+
+	return cursors, nil
+}
+
+func (s *sorter[D]) cursor(m D) (*store.Cursor, error) {
+	var keys []any
+	for _, v := range s.keyset(m).values {
+		keys = append(keys, v.Interface())
+	}
+
+	c, err := store.NewKeysetCursor(m.GetID(), keys...)
+	if err != nil {
+		return nil, err
+	}
+	c = c.Bind(s.fingerprint)
+
+	return &c, nil
+}
+
+// compare orders two keysets, returning -1, 0 or 1.
+func (s *sorter[D]) compare(a, b keyset) int {
+	for i, sort := range s.sorts {
+		if c := compareValues(a.values[i], b.values[i]); c != 0 {
+			if sort.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+
+	return strings.Compare(a.id, b.id)
+}
+
+func sortable(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// compareValues orders two values of the same [sortable] type.
+func compareValues(a, b reflect.Value) int {
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return compareOrdered(boolToInt(a.Bool()), boolToInt(b.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	}
+
+	return 0
+}
+
+func compareOrdered[T int | int64 | uint64 | float64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
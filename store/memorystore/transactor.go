@@ -0,0 +1,54 @@
+package memorystore
+
+import (
+	"context"
+)
+
+// Snapshotter is a store whose state can be captured and later restored.
+type Snapshotter interface {
+	// Snapshot holds off writes to the store, except those made with the
+	// returned context, and captures its state. Release lets writes resume,
+	// first restoring the captured state unless keep.
+	Snapshot(c context.Context) (tx context.Context, release func(keep bool))
+}
+
+// Transactor runs units of work across memory stores, rolling every store back
+// to its prior state if the unit of work fails.
+//
+// A unit of work holds off every other write to its stores until it is done,
+// so a rollback never discards them. Its own writes must be made with the
+// context it is passed, else they wait on it. Reads are not held off, and see
+// its writes as they are made. Stores are locked in the order they are given,
+// so transactors sharing stores should list them in the same order.
+type Transactor struct {
+	stores []Snapshotter
+}
+
+func NewTransactor(stores ...Snapshotter) *Transactor {
+	return &Transactor{stores: stores}
+}
+
+// RunInTx runs fn, restoring every store if it errors or panics. Nested calls
+// roll back only their own changes.
+func (t *Transactor) RunInTx(c context.Context, fn func(c context.Context) error) error {
+	var releases []func(keep bool)
+	for _, s := range t.stores {
+		var release func(keep bool)
+		c, release = s.Snapshot(c)
+		releases = append(releases, release)
+	}
+
+	committed := false
+	defer func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i](committed)
+		}
+	}()
+
+	if err := fn(c); err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
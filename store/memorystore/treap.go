@@ -0,0 +1,215 @@
+package memorystore
+
+import "math/rand"
+
+// treap is an immutable sorted map. Every change returns a new treap sharing
+// all but O(log n) nodes with the old one, which is left untouched, so readers
+// of an old treap are never disturbed by writers.
+//
+// Nodes are ordered by key and heap ordered by random priorities, which keeps
+// the tree balanced with high probability.
+type treap[K any, V any] struct {
+	root *treapNode[K, V]
+	cmp  func(a, b K) int
+}
+
+type treapNode[K any, V any] struct {
+	key      K
+	value    V
+	priority uint32
+	size     int
+
+	left, right *treapNode[K, V]
+}
+
+func newTreap[K any, V any](cmp func(a, b K) int) treap[K, V] {
+	return treap[K, V]{cmp: cmp}
+}
+
+func (t treap[K, V]) len() int {
+	return t.root.len()
+}
+
+func (n *treapNode[K, V]) len() int {
+	if n == nil {
+		return 0
+	}
+
+	return n.size
+}
+
+// resized returns n, which must be a copy, with its size updated.
+func (n *treapNode[K, V]) resized() *treapNode[K, V] {
+	n.size = 1 + n.left.len() + n.right.len()
+	return n
+}
+
+func (t treap[K, V]) get(k K) (V, bool) {
+	n := t.root
+	for n != nil {
+		c := t.cmp(k, n.key)
+		if c < 0 {
+			n = n.left
+		} else if c > 0 {
+			n = n.right
+		} else {
+			return n.value, true
+		}
+	}
+
+	return *new(V), false
+}
+
+// set returns t with k set to v.
+func (t treap[K, V]) set(k K, v V) treap[K, V] {
+	if _, ok := t.get(k); ok {
+		t.root = t.replace(t.root, k, v)
+	} else {
+		t.root = t.insert(t.root, &treapNode[K, V]{key: k, value: v, priority: rand.Uint32(), size: 1})
+	}
+
+	return t
+}
+
+// delete returns t without k.
+func (t treap[K, V]) delete(k K) treap[K, V] {
+	if _, ok := t.get(k); ok {
+		t.root = t.remove(t.root, k)
+	}
+
+	return t
+}
+
+// replace copies the path to k, which must be in n, setting its value.
+func (t treap[K, V]) replace(n *treapNode[K, V], k K, v V) *treapNode[K, V] {
+	copied := *n
+	if c := t.cmp(k, n.key); c < 0 {
+		copied.left = t.replace(n.left, k, v)
+	} else if c > 0 {
+		copied.right = t.replace(n.right, k, v)
+	} else {
+		copied.value = v
+	}
+
+	return &copied
+}
+
+// insert copies the path to where leaf belongs, whose key must not be in n.
+func (t treap[K, V]) insert(n *treapNode[K, V], leaf *treapNode[K, V]) *treapNode[K, V] {
+	if n == nil {
+		return leaf
+	}
+
+	if leaf.priority > n.priority {
+		leaf.left, leaf.right = t.split(n, leaf.key)
+		return leaf.resized()
+	}
+
+	copied := *n
+	if t.cmp(leaf.key, n.key) < 0 {
+		copied.left = t.insert(n.left, leaf)
+	} else {
+		copied.right = t.insert(n.right, leaf)
+	}
+
+	return copied.resized()
+}
+
+// split copies n into the nodes with keys before and after k, which must not
+// be in n.
+func (t treap[K, V]) split(n *treapNode[K, V], k K) (before, after *treapNode[K, V]) {
+	if n == nil {
+		return nil, nil
+	}
+
+	copied := *n
+	if t.cmp(k, n.key) < 0 {
+		before, copied.left = t.split(n.left, k)
+		return before, copied.resized()
+	}
+
+	copied.right, after = t.split(n.right, k)
+	return copied.resized(), after
+}
+
+// remove copies the path to k, which must be in n, removing it.
+func (t treap[K, V]) remove(n *treapNode[K, V], k K) *treapNode[K, V] {
+	c := t.cmp(k, n.key)
+	if c == 0 {
+		return join(n.left, n.right)
+	}
+
+	copied := *n
+	if c < 0 {
+		copied.left = t.remove(n.left, k)
+	} else {
+		copied.right = t.remove(n.right, k)
+	}
+
+	return copied.resized()
+}
+
+// join copies before and after into one treap. Every key in before must be
+// less than every key in after.
+func join[K any, V any](before, after *treapNode[K, V]) *treapNode[K, V] {
+	if before == nil {
+		return after
+	} else if after == nil {
+		return before
+	}
+
+	if before.priority > after.priority {
+		copied := *before
+		copied.right = join(before.right, after)
+		return copied.resized()
+	}
+
+	copied := *after
+	copied.left = join(before, after.left)
+	return copied.resized()
+}
+
+// ascend calls fn with each entry in order, starting from the first key not
+// less than from, or the first key if from is nil, until fn returns false.
+func (t treap[K, V]) ascend(from *K, fn func(k K, v V) bool) {
+	t.walk(t.root, from, fn)
+}
+
+func (t treap[K, V]) walk(n *treapNode[K, V], from *K, fn func(k K, v V) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if from != nil && t.cmp(n.key, *from) < 0 {
+		return t.walk(n.right, from, fn)
+	}
+	if !t.walk(n.left, from, fn) || !fn(n.key, n.value) {
+		return false
+	}
+
+	// Everything to the right follows from.
+	return t.walk(n.right, nil, fn)
+}
+
+// descend calls fn with each entry in reverse order, starting from the last
+// key not greater than from, or the last key if from is nil, until fn returns
+// false.
+func (t treap[K, V]) descend(from *K, fn func(k K, v V) bool) {
+	t.walkBack(t.root, from, fn)
+}
+
+func (t treap[K, V]) walkBack(n *treapNode[K, V], from *K, fn func(k K, v V) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if from != nil && t.cmp(n.key, *from) > 0 {
+		return t.walkBack(n.left, from, fn)
+	}
+	if !t.walkBack(n.right, from, fn) || !fn(n.key, n.value) {
+		return false
+	}
+
+	// Everything to the left precedes from.
+	return t.walkBack(n.left, nil, fn)
+}
@@ -8,35 +8,54 @@ import (
 	"github.com/pkg/errors"
 )
 
-type Tree[D store.TreeStorable] struct {
+type Tree[D store.TreeStorable, P MemoryParams[D]] struct {
 	d *data[D]
 }
 
-func NewTree[D store.TreeStorable](d *data[D]) *Tree[D] {
-	return &Tree[D]{d: d}
+func NewTree[D store.TreeStorable, P MemoryParams[D]](d *data[D]) *Tree[D, P] {
+	return &Tree[D, P]{d: d}
 }
 
-func (t *Tree[D]) ListAncestors(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	t.d.mu.RLock()
-	defer t.d.mu.RUnlock()
+func (t *Tree[D, P]) ListAncestors(c context.Context, rootId string, o ...store.AncestorOptions) (store.TreeResponse[D], error) {
+	if len(o) > 1 {
+		return store.TreeResponse[D]{}, errors.Wrap(store.ErrInvalidInput, "more than one set of options passed to ListAncestors")
+	}
+	var opts store.AncestorOptions
+	if len(o) > 0 {
+		opts = o[0]
+	}
+
+	return t.ancestors(t.d.read(c), rootId, opts)
+}
 
-	next, ok := t.d.store[rootId]
+func (t *Tree[D, P]) ancestors(v *version[D], rootId string, opts store.AncestorOptions) (store.TreeResponse[D], error) {
+	next, ok := v.get(rootId)
 	if !ok {
-		return store.TreeResponse[D]{}, errors.New("root not found")
+		return store.TreeResponse[D]{}, errors.Wrapf(store.ErrNotFound, "failed to find root %s", rootId)
+	}
+	match, err := t.matcher(v, opts.Filter)
+	if err != nil {
+		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to list ancestors")
 	}
 
 	// Follow the pointers!
-	layers := []store.Layer[D]{{PathLength: 0, Items: []D{next}}}
+	layers := []store.Layer[D]{{PathLength: 0, Items: []D{t.d.clone(next)}}}
 
-	height := 1
-	for next.GetParentID() != nil {
+	for height := 1; next.GetParentID() != nil; height++ {
 		id := next.GetParentID()
-		if maybeNext, ok := t.d.store[*id]; ok {
-			layers = append(layers, store.Layer[D]{PathLength: height, Items: []D{maybeNext}})
-			next = maybeNext
-		} else {
-			return store.TreeResponse[D]{}, errors.New("parent referenced but not found")
+		maybeNext, ok := v.get(*id)
+		if !ok {
+			return store.TreeResponse[D]{}, errors.Wrapf(store.ErrNotFound, "failed to find parent %s", *id)
+		}
+		next = maybeNext
+
+		if match != nil && len(match([]string{*id})) == 0 {
+			if opts.Filter.Prune {
+				break
+			}
+			continue
 		}
+		layers = append(layers, store.Layer[D]{PathLength: height, Items: []D{t.d.clone(next)}})
 	}
 
 	return store.TreeResponse[D]{
@@ -45,61 +64,194 @@ func (t *Tree[D]) ListAncestors(c context.Context, rootId string) (store.TreeRes
 	}, nil
 }
 
-func (t *Tree[D]) ListDescendants(c context.Context, rootId string) (store.TreeResponse[D], error) {
-	t.d.mu.RLock()
-	defer t.d.mu.RUnlock()
+func (t *Tree[D, P]) LowestCommonAncestor(c context.Context, a, b string) (store.CommonAncestor[D], bool, error) {
+	path, found, err := t.PathBetween(c, a, b)
+	if err != nil || !found {
+		return store.CommonAncestor[D]{}, false, err
+	}
 
-	start, ok := t.d.store[rootId]
-	if !ok {
-		return store.TreeResponse[D]{}, errors.New("root not found")
+	return path.CommonAncestor(), true, nil
+}
+
+// PathBetween follows the parents of both nodes in the same version, so a
+// concurrent move cannot tear the path.
+func (t *Tree[D, P]) PathBetween(c context.Context, a, b string) (store.TreePath[D], bool, error) {
+	v := t.d.read(c)
+	ancestorsA, err := t.ancestors(v, a, store.AncestorOptions{})
+	if err != nil {
+		return store.TreePath[D]{}, false, errors.Wrap(err, "failed to find path")
+	}
+	ancestorsB, err := t.ancestors(v, b, store.AncestorOptions{})
+	if err != nil {
+		return store.TreePath[D]{}, false, errors.Wrap(err, "failed to find path")
 	}
 
-	// memoize parentId => []children
-	mapParentChildren := make(map[string][]string, len(t.d.store))
-	for _, node := range t.d.store {
-		parentId := node.GetParentID()
-		if parentId == nil {
-			continue
+	path, found := store.JoinAncestors(ancestorsA, ancestorsB)
+	return path, found, nil
+}
+
+func (t *Tree[D, P]) Move(c context.Context, id string, parentID *string) (*D, bool, error) {
+	if _, ok := any(*new(D)).(store.Movable[D]); !ok {
+		return nil, false, errors.Wrapf(store.ErrInvalidInput, "%s are not movable", (*new(D)).TableName())
+	}
+
+	defer t.d.lock(c)()
+
+	existing, exists := t.d.get(id)
+	if !exists {
+		return nil, false, nil
+	}
+
+	// Walk up from the new parent: finding the node means it is moving under
+	// its own subtree.
+	seen := make(map[string]bool)
+	for next := parentID; next != nil && !seen[*next]; {
+		if *next == id {
+			return nil, false, errors.Wrapf(store.ErrCycle, "failed to move %s under %s", id, *parentID)
 		}
+		seen[*next] = true
 
-		children, ok := mapParentChildren[*parentId]
-		if !ok {
-			mapParentChildren[*parentId] = []string{node.GetID()}
-		} else {
-			mapParentChildren[*parentId] = append(children, node.GetID())
+		ancestor, ok := t.d.get(*next)
+		if !ok && next == parentID {
+			return nil, false, errors.Wrapf(store.ErrNotFound, "failed to find parent %s", *parentID)
+		} else if !ok {
+			break
 		}
+		next = ancestor.GetParentID()
 	}
 
-	// More efficient to insert each child in correct order above, but fuck it.
-	for _, children := range mapParentChildren {
-		sort.SliceStable(children, func(i, j int) bool {
-			return children[i] < children[j]
-		})
+	moved := bumpVersion(existing, any(existing).(store.Movable[D]).WithParentID(parentID))
+	if err := t.d.apply(set(id, moved)); err != nil {
+		return nil, false, errors.Wrap(err, "failed to move record")
 	}
 
-	layers := []store.Layer[D]{{PathLength: 0, Items: []D{start}}}
-	count := 1
+	moved = t.d.clone(moved)
+	return &moved, true, nil
+}
 
-	// I mean, sure use a real queue if you want better perf, but we're ok here.
-	queue := []string{start.GetID()}
-	depth := 1
-	for len(queue) > 0 {
-		next := queue[0]
-		queue = queue[1:] // Deuque.
+func (t *Tree[D, P]) ListDescendants(c context.Context, rootId string, o ...store.DescendantOptions) (store.TreeResponse[D], error) {
+	if len(o) > 1 {
+		return store.TreeResponse[D]{}, errors.Wrap(store.ErrInvalidInput, "more than one set of options passed to ListDescendants")
+	}
+	var opts store.DescendantOptions
+	if len(o) > 0 {
+		opts = o[0]
+	}
+	after, err := opts.Check(rootId)
+	if err != nil {
+		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to list descendants")
+	}
+	maxDepth := opts.MaxDepth
+	if after != nil {
+		maxDepth = after.PathLength
+	}
 
-		var items []D
-		for _, childId := range mapParentChildren[next] {
-			count++
-			items = append(items, t.d.store[childId])
-			queue = append(queue, childId)
+	v := t.d.read(c)
+	if _, ok := v.get(rootId); !ok {
+		return store.TreeResponse[D]{}, errors.Wrapf(store.ErrNotFound, "failed to find root %s", rootId)
+	}
+	match, err := t.matcher(v, opts.Filter)
+	if err != nil {
+		return store.TreeResponse[D]{}, errors.Wrap(err, "failed to list descendants")
+	}
+	prune := opts.Filter != nil && opts.Filter.Prune
+
+	// Walk down a layer at a time, finding children in the parent index. Each
+	// layer is walked whole to find the next, but only the nodes that might be
+	// listed are copied.
+	var layers []store.Layer[D]
+	listed := 0
+	ids := []string{rootId}
+	for depth := 0; len(ids) > 0 && (maxDepth == 0 || depth <= maxDepth); depth++ {
+		if depth > 0 {
+			var children []string
+			for _, parentId := range ids {
+				children = append(children, t.d.indexes.childrenOf(v, parentId)...)
+			}
+			sort.Strings(children)
+			ids = children
+		}
+
+		candidates := ids
+		if match != nil && depth > 0 {
+			candidates = match(ids)
+			if prune {
+				ids = candidates
+			}
+		}
+		if after != nil {
+			if depth < after.PathLength {
+				continue
+			}
+			i := sort.SearchStrings(candidates, after.ID)
+			if i < len(candidates) && candidates[i] == after.ID {
+				i++
+			}
+			candidates = candidates[i:]
+		}
+		if opts.LayerLimit > 0 && len(candidates) > opts.LayerLimit+1 {
+			candidates = candidates[:opts.LayerLimit+1]
+		}
+		if opts.MaxNodes > 0 && listed+len(candidates) > opts.MaxNodes+1 {
+			candidates = candidates[:opts.MaxNodes+1-listed]
+		}
+		if len(candidates) == 0 {
+			continue
 		}
 
+		items := make([]D, len(candidates))
+		for i, id := range candidates {
+			m, _ := v.get(id)
+			items[i] = t.d.clone(m)
+		}
 		layers = append(layers, store.Layer[D]{PathLength: depth, Items: items})
-		depth++
+		listed += len(items)
+		if opts.MaxNodes > 0 && listed > opts.MaxNodes {
+			break
+		}
 	}
 
-	return store.TreeResponse[D]{
-		Layers: layers,
-		Count:  count,
+	return store.TrimLayers(rootId, opts, layers), nil
+}
+
+// matcher returns a func picking, in order, the nodes with ids that the
+// filter's params would list from v, or nil if there is no filter. Only the
+// store's own params can filter, since others may not apply to its models.
+func (t *Tree[D, P]) matcher(v *version[D], f *store.TreeFilter) (func(ids []string) []string, error) {
+	if f == nil {
+		return nil, nil
+	}
+	params, ok := f.Params.(P)
+	if !ok {
+		return nil, errors.Wrapf(store.ErrInvalidInput, "%T cannot filter %s", f.Params, (*new(D)).TableName())
+	}
+
+	var filter IndexFilter
+	if f, ok := any(params).(IndexFilterer); ok {
+		filter = f.IndexFilter()
+	}
+	indexed, filtered, err := t.d.indexes.filter(v, filter)
+	if err != nil {
+		return nil, err
+	}
+	in := make(map[string]bool, len(indexed))
+	for _, id := range indexed {
+		in[id] = true
+	}
+
+	deleted := store.DeletedFilterOf(params)
+	return func(ids []string) []string {
+		var candidates []D
+		for _, id := range ids {
+			if m, _ := v.get(id); (!filtered || in[id]) && listed(m, deleted) {
+				candidates = append(candidates, m)
+			}
+		}
+
+		var matched []string
+		for _, m := range params.MemoryFilter(candidates) {
+			matched = append(matched, m.GetID())
+		}
+		return matched
 	}, nil
 }
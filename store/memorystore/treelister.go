@@ -0,0 +1,84 @@
+package memorystore
+
+import (
+	"context"
+	"pckilgore/app/store"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// TreeLister lists nodes by their place in a tree, finding candidates in the
+// parent index before listing them as a [Lister] would.
+type TreeLister[D store.TreeStorable, P MemoryParams[D]] struct {
+	d *data[D]
+	l *Lister[D, P]
+}
+
+func NewTreeLister[D store.TreeStorable, P MemoryParams[D]](d *data[D]) *TreeLister[D, P] {
+	return &TreeLister[D, P]{d: d, l: NewLister[D, P](d)}
+}
+
+func (t *TreeLister[D, P]) ListChildren(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return t.l.list(c, params, func(v *version[D]) ([]string, error) {
+		if _, ok := v.get(id); !ok {
+			return nil, errors.Wrapf(store.ErrNotFound, "failed to find node %s", id)
+		}
+		return t.d.indexes.childrenOf(v, id), nil
+	})
+}
+
+func (t *TreeLister[D, P]) ListSiblings(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return t.l.list(c, params, func(v *version[D]) ([]string, error) {
+		node, ok := v.get(id)
+		if !ok {
+			return nil, errors.Wrapf(store.ErrNotFound, "failed to find node %s", id)
+		}
+
+		var siblings []string
+		if parent := node.GetParentID(); parent != nil {
+			siblings = t.d.indexes.childrenOf(v, *parent)
+		} else {
+			siblings = t.d.indexes.roots(v)
+		}
+
+		others := siblings[:0:0]
+		for _, sibling := range siblings {
+			if sibling != id {
+				others = append(others, sibling)
+			}
+		}
+		return others, nil
+	})
+}
+
+func (t *TreeLister[D, P]) ListRoots(c context.Context, params P) (store.ListResponse[D], error) {
+	return t.l.list(c, params, func(v *version[D]) ([]string, error) {
+		return t.d.indexes.roots(v), nil
+	})
+}
+
+func (t *TreeLister[D, P]) ListLeaves(c context.Context, id string, params P) (store.ListResponse[D], error) {
+	return t.l.list(c, params, func(v *version[D]) ([]string, error) {
+		if _, ok := v.get(id); !ok {
+			return nil, errors.Wrapf(store.ErrNotFound, "failed to find node %s", id)
+		}
+
+		// Walk down a layer at a time, keeping the nodes with no children.
+		var leaves []string
+		for parents := t.d.indexes.childrenOf(v, id); len(parents) > 0; {
+			var next []string
+			for _, parent := range parents {
+				children := t.d.indexes.childrenOf(v, parent)
+				if len(children) == 0 {
+					leaves = append(leaves, parent)
+				}
+				next = append(next, children...)
+			}
+			parents = next
+		}
+		sort.Strings(leaves)
+
+		return leaves, nil
+	})
+}
@@ -0,0 +1,88 @@
+package memorystore
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"pckilgore/app/store"
+)
+
+type Updater[D store.Storable] struct {
+	d *data[D]
+}
+
+func NewUpdater[D store.Storable](d *data[D]) *Updater[D] {
+	return &Updater[D]{d: d}
+}
+
+func (u *Updater[D]) Update(c context.Context, storable D) (*D, bool, error) {
+	defer u.d.lock(c)()
+
+	existing, exists := u.d.get(storable.GetID())
+	if !exists {
+		return nil, false, nil
+	}
+
+	if err := checkVersion(existing, storable); err != nil {
+		return nil, false, errors.Wrap(err, "failed to update record")
+	}
+	storable = bumpVersion(existing, storable)
+
+	if err := u.d.apply(set(storable.GetID(), storable)); err != nil {
+		return nil, false, errors.Wrap(err, "failed to update record")
+	}
+
+	return &storable, true, nil
+}
+
+func (u *Updater[D]) Patch(c context.Context, storable D, fields ...string) (*D, bool, error) {
+	// Validate the fields even if there turns out to be no model to patch.
+	if _, err := patch(storable, storable, fields); err != nil {
+		return nil, false, err
+	}
+
+	defer u.d.lock(c)()
+
+	existing, exists := u.d.get(storable.GetID())
+	if !exists {
+		return nil, false, nil
+	}
+
+	patched, err := patch(existing, storable, fields)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Like other stores, an empty field mask changes nothing, so cannot
+	// conflict.
+	if len(fields) > 0 {
+		if err := checkVersion(existing, storable); err != nil {
+			return nil, false, errors.Wrap(err, "failed to patch record")
+		}
+		patched = bumpVersion(existing, patched)
+	}
+
+	if err := u.d.apply(set(storable.GetID(), patched)); err != nil {
+		return nil, false, errors.Wrap(err, "failed to patch record")
+	}
+
+	patched = u.d.clone(patched)
+	return &patched, true, nil
+}
+
+// patch returns to with the named fields copied from from. Since to is a copy,
+// nothing is written unless every field is valid.
+func patch[D any](to D, from D, fields []string) (D, error) {
+	dst := reflect.ValueOf(&to).Elem()
+	src := reflect.ValueOf(from)
+	for _, field := range fields {
+		f := dst.FieldByName(field)
+		if !f.IsValid() || !f.CanSet() {
+			return to, errors.Wrapf(store.ErrInvalidInput, "cannot write unknown field %q", field)
+		}
+		f.Set(src.FieldByName(field))
+	}
+
+	return to, nil
+}
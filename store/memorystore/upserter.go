@@ -0,0 +1,63 @@
+package memorystore
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"pckilgore/app/store"
+)
+
+type Upserter[D store.Storable] struct {
+	d *data[D]
+}
+
+func NewUpserter[D store.Storable](d *data[D]) *Upserter[D] {
+	return &Upserter[D]{d: d}
+}
+
+func (u *Upserter[D]) Upsert(c context.Context, storable D, on store.OnConflict) (*D, store.UpsertOutcome, error) {
+	switch on.Strategy {
+	case store.ConflictDoNothing, store.ConflictOverwrite:
+	case store.ConflictOverwriteFields:
+		// Validate the fields even if there turns out to be no conflict.
+		if _, err := patch(storable, storable, on.Fields); err != nil {
+			return nil, 0, err
+		}
+	default:
+		return nil, 0, errors.Wrapf(store.ErrInvalidInput, "unknown conflict strategy %d", on.Strategy)
+	}
+
+	defer u.d.lock(c)()
+
+	existing, exists := u.d.get(storable.GetID())
+	if !exists {
+		storable = initialVersion(storable)
+		if err := u.d.apply(set(storable.GetID(), storable)); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to upsert record")
+		}
+		return &storable, store.UpsertInserted, nil
+	}
+
+	switch {
+	case on.Strategy == store.ConflictOverwrite:
+		storable = bumpVersion(existing, storable)
+		if err := u.d.apply(set(storable.GetID(), storable)); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to upsert record")
+		}
+		return &storable, store.UpsertUpdated, nil
+	case on.Strategy == store.ConflictOverwriteFields && len(on.Fields) > 0:
+		patched, err := patch(existing, storable, on.Fields)
+		if err != nil {
+			return nil, 0, err
+		}
+		patched = bumpVersion(existing, patched)
+		if err := u.d.apply(set(storable.GetID(), patched)); err != nil {
+			return nil, 0, errors.Wrap(err, "failed to upsert record")
+		}
+		patched = u.d.clone(patched)
+		return &patched, store.UpsertUpdated, nil
+	}
+
+	existing = u.d.clone(existing)
+	return &existing, store.UpsertSkipped, nil
+}
@@ -0,0 +1,36 @@
+package memorystore
+
+import "pckilgore/app/store"
+
+// initialVersion returns m at version 1, if it is versioned.
+func initialVersion[D any](m D) D {
+	if v, ok := any(m).(store.Versioned[D]); ok {
+		return v.WithVersion(1)
+	}
+
+	return m
+}
+
+// checkVersion errors if m is versioned, and not at the version of existing.
+func checkVersion[D store.Storable](existing D, m D) error {
+	v, ok := any(m).(store.Versioned[D])
+	if !ok {
+		return nil
+	}
+
+	current := any(existing).(store.Versioned[D]).GetVersion()
+	if v.GetVersion() != current {
+		return &store.VersionConflictError{ID: m.GetID(), Version: v.GetVersion(), Current: current}
+	}
+
+	return nil
+}
+
+// bumpVersion returns m at the version after existing's, if it is versioned.
+func bumpVersion[D any](existing D, m D) D {
+	if v, ok := any(m).(store.Versioned[D]); ok {
+		return v.WithVersion(any(existing).(store.Versioned[D]).GetVersion() + 1)
+	}
+
+	return m
+}
@@ -0,0 +1,133 @@
+package memorystore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"pckilgore/app/store"
+)
+
+// watchHistory is how many of the latest changes a memory store keeps for
+// watchers to resume from.
+const watchHistory = 4096
+
+// feed keeps the latest changes to data for watchers. Durable stores persist
+// the sequence number of the last change and continue from it when reopened,
+// so a token from before then resumes if nothing after it was missed, and has
+// otherwise expired.
+type feed[T any] struct {
+	mu      sync.Mutex
+	seq     int64
+	changes []store.Change[T]
+
+	// more is closed, and replaced, when changes are appended.
+	more chan struct{}
+}
+
+func newFeed[T any]() *feed[T] {
+	return &feed[T]{more: make(chan struct{})}
+}
+
+// append numbers changes and wakes watchers. Changes must not be modified
+// afterwards.
+func (f *feed[T]) append(changes []store.Change[T]) {
+	if len(changes) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range changes {
+		f.seq++
+		changes[i].Seq = f.seq
+	}
+	f.changes = append(f.changes, changes...)
+
+	// Trim to the history in bulk, so trimming is amortized over many writes.
+	if len(f.changes) > 2*watchHistory {
+		f.changes = append([]store.Change[T](nil), f.changes[len(f.changes)-watchHistory:]...)
+	}
+
+	close(f.more)
+	f.more = make(chan struct{})
+}
+
+// last returns the sequence number of the latest change.
+func (f *feed[T]) last() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.seq
+}
+
+// since returns the changes after seq, or every change kept if seq is
+// negative, and a channel closed when there are more.
+func (f *feed[T]) since(seq int64) ([]store.Change[T], <-chan struct{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	oldest := f.seq - int64(len(f.changes))
+	if seq < 0 {
+		seq = oldest
+	}
+	if seq < oldest || seq > f.seq {
+		return nil, nil, errors.Wrapf(store.ErrTokenExpired, "changes after %d are not kept", seq)
+	}
+
+	return f.changes[seq-oldest:], f.more, nil
+}
+
+type Watcher[D store.Storable] struct {
+	d *data[D]
+}
+
+func NewWatcher[D store.Storable](d *data[D]) *Watcher[D] {
+	return &Watcher[D]{d: d}
+}
+
+// Watch implements [store.Watcher]. Changes are made by writes as they are
+// applied, so rolled back transactions are followed by changes undoing them.
+func (w *Watcher[D]) Watch(c context.Context, after store.ResumeToken, fn func(store.Change[D]) error) error {
+	seq := int64(-1)
+	if after != "" {
+		var err error
+		if seq, err = after.Seq(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		changes, more, err := w.d.feed.since(seq)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if change.Model != nil {
+				m := w.d.clone(*change.Model)
+				change.Model = &m
+			}
+			if err := fn(change); err != nil {
+				if errors.Is(err, store.ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+			seq = change.Seq
+		}
+
+		if len(changes) == 0 {
+			select {
+			case <-c.Done():
+				return c.Err()
+			case <-more:
+			}
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package store
 
-import "github.com/pkg/errors"
-
 type ListResponse[Model any] struct {
 	// Items includes models matching the query parameters up to the limit,
 	// wherein it represents a single page of responses matching the query.
 	Items []Model
 
+	// Cursors point at each of [Items], in the same order. Listers that leave
+	// it empty are assumed to list by ID.
+	Cursors []Cursor
+
 	// Count is the total number of items available, irrespective of any limit or
-	// pagination.
+	// pagination. It is approximate when the parameters' [CountMode] is
+	// [CountEstimate], and -1 when it is [CountNone].
 	Count int
 
 	// After can be provided by parameters to retreive the page of results
@@ -24,10 +27,36 @@ type Parameterized interface {
 	Limit() int
 	After() *Cursor
 	Before() *Cursor
+
+	// Sort is the order of the list, from most to least significant field. The
+	// ID is always the final tiebreaker, so an empty Sort orders by ID.
+	Sort() []Sort
+
+	// CountMode is how the list computes its total count.
+	CountMode() CountMode
 }
 
-type InvalidPaginationParamsErr error
+// CountMode controls how a list computes [ListResponse] Count.
+type CountMode int
+
+const (
+	// CountExact counts every record matching the filter.
+	CountExact CountMode = iota
+
+	// CountNone skips counting, which can be expensive on large tables.
+	CountNone
+
+	// CountEstimate asks the store for a cheap approximate count. Stores that
+	// can't estimate count exactly.
+	CountEstimate
+)
+
+// Sort orders a list by a field of the model.
+type Sort struct {
+	// Field is named as on the Go struct, e.g. "Name". Sorting by nullable
+	// (pointer) fields is not supported.
+	Field string
 
-func NewInvalidPaginationParamsErr(msg string) InvalidPaginationParamsErr {
-	return errors.New(msg)
+	// Desc sorts the field in descending order.
+	Desc bool
 }
@@ -1,12 +1,21 @@
 package pagination
 
-import "pckilgore/app/store"
+import (
+	"pckilgore/app/store"
+
+	"github.com/pkg/errors"
+)
 
 // Pagination is a basic implementation of store.Parameterized.
 type Pagination struct {
 	limit  int
 	before *store.Cursor
 	after  *store.Cursor
+	sort   []store.Sort
+	count  store.CountMode
+
+	// requested are the parameters before any policy was applied.
+	requested Params
 }
 
 // Options are parameters to construct [Params].
@@ -14,15 +23,94 @@ type Params struct {
 	Limit  int
 	Before *store.Cursor
 	After  *store.Cursor
+	Sort   []store.Sort
+
+	// Count overrides how the list is counted, if set.
+	Count *store.CountMode
+}
+
+// Policy decides which [Params] are acceptable, and fills in defaults.
+type Policy struct {
+	// DefaultLimit is the limit when none is requested.
+	DefaultLimit int
+
+	// MaxLimit is the largest limit allowed, or zero for no maximum.
+	MaxLimit int
+
+	// ClampToMax lowers limits over MaxLimit to MaxLimit. Otherwise, they are
+	// an error.
+	ClampToMax bool
+
+	// Count is how lists compute their total count, unless [Params] override
+	// it.
+	Count store.CountMode
+}
+
+// DefaultPolicy is the policy of [New].
+var DefaultPolicy = Policy{
+	DefaultLimit: 100,
+	MaxLimit:     100,
+	Count:        store.CountExact,
+}
+
+// New constructs [Pagination] under [DefaultPolicy], or errors with
+// [store.ErrInvalidPagination] if p is not allowed.
+func New(p Params) (Pagination, error) {
+	return DefaultPolicy.New(p)
+}
+
+// MustNew is like [New], but panics if p is not allowed. It is for
+// parameters known to be valid, like those written out in code.
+func MustNew(p Params) Pagination {
+	pagination, err := New(p)
+	if err != nil {
+		panic(err)
+	}
+
+	return pagination
 }
 
-func New(p Params) Pagination {
-	limit := 100
-	if p.Limit > 0 && p.Limit < 100 {
-		limit = p.Limit
+// New constructs [Pagination] under the policy, or errors with
+// [store.ErrInvalidPagination] if p is not allowed.
+func (policy Policy) New(p Params) (Pagination, error) {
+	limit := p.Limit
+	if limit < 0 {
+		return Pagination{}, errors.Wrapf(store.ErrInvalidPagination, "limit %d cannot be negative", limit)
+	} else if limit == 0 {
+		limit = policy.DefaultLimit
+	}
+
+	if policy.MaxLimit > 0 && limit > policy.MaxLimit {
+		if !policy.ClampToMax {
+			return Pagination{}, errors.Wrapf(
+				store.ErrInvalidPagination,
+				"limit %d exceeds maximum of %d",
+				limit,
+				policy.MaxLimit,
+			)
+		}
+		limit = policy.MaxLimit
+	}
+
+	count := policy.Count
+	if p.Count != nil {
+		count = *p.Count
 	}
 
-	return Pagination{limit: limit, before: p.Before, after: p.After}
+	return Pagination{
+		limit:     limit,
+		before:    p.Before,
+		after:     p.After,
+		sort:      p.Sort,
+		count:     count,
+		requested: p,
+	}, nil
+}
+
+// Under applies a different policy to the parameters p was constructed with,
+// so services can enforce their own policy on parameters built elsewhere.
+func (p Pagination) Under(policy Policy) (Pagination, error) {
+	return policy.New(p.requested)
 }
 
 func (p Pagination) Limit() int {
@@ -36,3 +124,11 @@ func (p Pagination) After() *store.Cursor {
 func (p Pagination) Before() *store.Cursor {
 	return p.before
 }
+
+func (p Pagination) Sort() []store.Sort {
+	return p.sort
+}
+
+func (p Pagination) CountMode() store.CountMode {
+	return p.count
+}
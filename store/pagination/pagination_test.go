@@ -0,0 +1,79 @@
+package pagination_test
+
+import (
+	"testing"
+
+	"pckilgore/app/store"
+	"pckilgore/app/store/pagination"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	for requested, want := range map[int]int{0: 100, 1: 1, 99: 99, 100: 100} {
+		p, err := pagination.New(pagination.Params{Limit: requested})
+		require.Nil(t, err)
+		require.Equalf(t, want, p.Limit(), "limit %d", requested)
+	}
+
+	for _, requested := range []int{-1, 101, 1000} {
+		_, err := pagination.New(pagination.Params{Limit: requested})
+		require.ErrorIsf(t, err, store.ErrInvalidPagination, "limit %d should not be clamped", requested)
+	}
+
+	require.Panics(t, func() { pagination.MustNew(pagination.Params{Limit: 1000}) })
+}
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	strict := pagination.Policy{DefaultLimit: 20, MaxLimit: 50}
+	clamped := pagination.Policy{DefaultLimit: 20, MaxLimit: 50, ClampToMax: true}
+	unbounded := pagination.Policy{DefaultLimit: 20, Count: store.CountNone}
+
+	t.Run("defaults", func(t *testing.T) {
+		p, err := strict.New(pagination.Params{})
+		require.Nil(t, err)
+		require.Equal(t, 20, p.Limit())
+		require.Equal(t, store.CountExact, p.CountMode())
+
+		p, err = unbounded.New(pagination.Params{})
+		require.Nil(t, err)
+		require.Equal(t, store.CountNone, p.CountMode())
+	})
+
+	t.Run("over max", func(t *testing.T) {
+		_, err := strict.New(pagination.Params{Limit: 51})
+		require.ErrorIs(t, err, store.ErrInvalidPagination)
+
+		p, err := clamped.New(pagination.Params{Limit: 51})
+		require.Nil(t, err)
+		require.Equal(t, 50, p.Limit())
+
+		p, err = unbounded.New(pagination.Params{Limit: 10000})
+		require.Nil(t, err)
+		require.Equal(t, 10000, p.Limit())
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := clamped.New(pagination.Params{Limit: -1})
+		require.ErrorIs(t, err, store.ErrInvalidPagination)
+	})
+
+	t.Run("under", func(t *testing.T) {
+		after := store.NewCursor("a")
+		built, err := clamped.New(pagination.Params{Limit: 1000, After: &after})
+		require.Nil(t, err)
+		require.Equal(t, 50, built.Limit())
+
+		p, err := built.Under(unbounded)
+		require.Nil(t, err)
+		require.Equal(t, 1000, p.Limit(), "the requested limit should be re-applied, not the clamped one")
+		require.Equal(t, &after, p.After())
+
+		_, err = built.Under(strict)
+		require.ErrorIs(t, err, store.ErrInvalidPagination)
+	})
+}
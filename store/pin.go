@@ -0,0 +1,14 @@
+package store
+
+import "context"
+
+// Pinner pins a consistent point-in-time view of a store for reads. Reads made
+// with the pinned context, such as every page of an [Iterate], see the store
+// as it was when pinned, however it is written to meanwhile. Writes are
+// unaffected, so are not seen by pinned reads.
+type Pinner interface {
+	// Pin returns ctx with the current state of the store pinned, and a func
+	// that unpins it. Once unpinned, reads with the context see the latest
+	// state again.
+	Pin(ctx context.Context) (pinned context.Context, release func())
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// SoftDeletable models are marked deleted rather than removed. [Deleter]
+// Delete, [BatchDeleter] DeleteMany and [VersionedDeleter] DeleteVersion mark
+// them, and report whether a live record was marked. Retrieve still returns
+// deleted records, so callers can tell them apart from missing ones, but lists
+// exclude them unless the parameters are a [DeletedFilterer].
+type SoftDeletable[Model any] interface {
+	// GetDeletedAt returns when the model was deleted, or nil if it is live.
+	GetDeletedAt() *time.Time
+
+	// WithDeletedAt returns a copy of the model deleted at, or live if nil.
+	WithDeletedAt(at *time.Time) Model
+
+	// GetDeletedAtField returns the name of the column holding the deletion
+	// time.
+	GetDeletedAtField() string
+}
+
+// Now returns the time to mark a [SoftDeletable] record deleted at: the
+// current time in UTC, without a monotonic clock reading, so it compares and
+// serializes the same whichever store it round trips through.
+func Now() time.Time {
+	return time.Now().UTC().Round(0)
+}
+
+// SoftDeleter manages deleted [SoftDeletable] records. Marking and clearing
+// deletion are writes, so advance the version of [Versioned] models.
+type SoftDeleter interface {
+	// Restore makes a deleted record live again, reporting whether it was
+	// deleted. Models that are not soft deletable error with [ErrInvalidInput].
+	Restore(ctx context.Context, id string) (bool, error)
+
+	// Purge permanently removes a record, whether or not it was deleted,
+	// reporting whether it existed.
+	Purge(ctx context.Context, id string) (bool, error)
+}
+
+// DeletedFilter is which [SoftDeletable] records a list includes.
+type DeletedFilter int
+
+const (
+	// ExcludeDeleted lists only live records.
+	ExcludeDeleted DeletedFilter = iota
+
+	// IncludeDeleted lists both live and deleted records.
+	IncludeDeleted
+
+	// OnlyDeleted lists only deleted records.
+	OnlyDeleted
+)
+
+// DeletedFilterer is implemented by parameters that can list deleted records.
+// Other parameters are [ExcludeDeleted].
+type DeletedFilterer interface {
+	DeletedFilter() DeletedFilter
+}
+
+// DeletedFilterOf returns the [DeletedFilter] of p.
+func DeletedFilterOf(p Parameterized) DeletedFilter {
+	if f, ok := p.(DeletedFilterer); ok {
+		return f.DeletedFilter()
+	}
+
+	return ExcludeDeleted
+}
@@ -27,13 +27,57 @@ type Creator[Model Storable] interface {
 	Create(ctx context.Context, m Model) (*Model, error)
 }
 
+// Updater writes changes to an existing record. Neither method creates a record
+// that does not exist; both report whether the record was found.
+type Updater[Model Storable] interface {
+	// Update replaces the record sharing m's ID with m.
+	Update(ctx context.Context, m Model) (*Model, bool, error)
+
+	// Patch copies only the named fields from m onto the record sharing m's ID.
+	// Fields are named as on the Go struct, e.g. "Name". An empty field mask
+	// changes nothing.
+	Patch(ctx context.Context, m Model, fields ...string) (*Model, bool, error)
+}
+
+// BatchCreator creates many records at once. Creation is all or nothing: if any
+// record conflicts with an existing record or another in the batch, none are
+// created and the error wraps [ErrAlreadyExists].
+type BatchCreator[Model Storable] interface {
+	// CreateMany returns the created records in the order given.
+	CreateMany(ctx context.Context, ms []Model) ([]Model, error)
+}
+
+// BatchDeleter deletes many records at once. Deletion is all or nothing: on
+// error, no records are deleted. Missing records are not an error.
+type BatchDeleter[Model Storable] interface {
+	// DeleteMany returns the number of records that were deleted.
+	DeleteMany(ctx context.Context, ids []string) (int, error)
+}
+
+// Upserter creates a record, or resolves a conflict with the existing record
+// sharing its ID, in a single atomic operation.
+type Upserter[Model Storable] interface {
+	// Upsert returns the record as stored, and whether it was inserted, updated
+	// or left unchanged. Unknown strategies or fields error with
+	// [ErrInvalidInput].
+	Upsert(ctx context.Context, m Model, on OnConflict) (*Model, UpsertOutcome, error)
+}
+
 type Lister[Model Storable, Params Parameterized] interface {
 	List(ctx context.Context, p Params) (ListResponse[Model], error)
 }
 
+// Transactor runs a unit of work atomically. Store operations passed the
+// callback's context share one transaction: if the callback errors, none of
+// them take effect.
+type Transactor interface {
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type Store[Model Storable, Params Parameterized] interface {
 	Retriever[Model]
 	Creator[Model]
+	Updater[Model]
 	Deleter[Model]
 	Lister[Model, Params]
 }
@@ -0,0 +1,148 @@
+package store_test
+
+import (
+	"fmt"
+	. "pckilgore/app/store"
+	"pckilgore/app/store/pagination"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// VersionedModel is a minimal [Versioned] model, for testing stores with
+// [CreateVersionedTest].
+type VersionedModel struct {
+	ID      string
+	Name    string
+	Version int64
+}
+
+func (VersionedModel) TableName() string {
+	return "versioned_models"
+}
+
+func (m VersionedModel) GetID() string {
+	return m.ID
+}
+
+func (VersionedModel) NewID() string {
+	return uuid.NewString()
+}
+
+// NewVersionedModel builds a [VersionedModel] for the suites, with an ID
+// unique to nonce.
+func NewVersionedModel(nonce int) VersionedModel {
+	return VersionedModel{
+		ID:   fmt.Sprintf("%03d", nonce),
+		Name: fmt.Sprintf("testing model %d", nonce),
+	}
+}
+
+// RenameVersionedModel changes the name of a [VersionedModel], for the suites.
+func RenameVersionedModel(m VersionedModel) (VersionedModel, []string) {
+	m.Name = m.Name + " (updated)"
+	return m, []string{"Name"}
+}
+
+func (m VersionedModel) GetVersion() int64 {
+	return m.Version
+}
+
+func (m VersionedModel) WithVersion(version int64) VersionedModel {
+	m.Version = version
+	return m
+}
+
+func (VersionedModel) GetVersionField() string {
+	return "version"
+}
+
+// VersionedParams lists every [VersionedModel] in any store.
+type VersionedParams struct {
+	pagination.Pagination
+}
+
+func (VersionedParams) GormFilter(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+func (VersionedParams) MemoryFilter(in []VersionedModel) []VersionedModel {
+	return in
+}
+
+// DeletableModel is a minimal [SoftDeletable] model, for testing stores with
+// [CreateSoftDeleteTest]. It is also [Versioned].
+type DeletableModel struct {
+	ID        string
+	Name      string
+	Version   int64
+	DeletedAt *time.Time
+}
+
+func (DeletableModel) TableName() string {
+	return "deletable_models"
+}
+
+func (m DeletableModel) GetID() string {
+	return m.ID
+}
+
+func (DeletableModel) NewID() string {
+	return uuid.NewString()
+}
+
+func (m DeletableModel) GetVersion() int64 {
+	return m.Version
+}
+
+func (m DeletableModel) WithVersion(version int64) DeletableModel {
+	m.Version = version
+	return m
+}
+
+func (DeletableModel) GetVersionField() string {
+	return "version"
+}
+
+func (m DeletableModel) GetDeletedAt() *time.Time {
+	return m.DeletedAt
+}
+
+// NewDeletableModel builds a [DeletableModel] for the suites, with an ID
+// unique to nonce.
+func NewDeletableModel(nonce int) DeletableModel {
+	return DeletableModel{
+		ID:   fmt.Sprintf("%03d", nonce),
+		Name: fmt.Sprintf("testing model %d", nonce),
+	}
+}
+
+func (m DeletableModel) WithDeletedAt(at *time.Time) DeletableModel {
+	m.DeletedAt = at
+	return m
+}
+
+func (DeletableModel) GetDeletedAtField() string {
+	return "deleted_at"
+}
+
+// DeletableParams lists every [DeletableModel] in any store, under a
+// [DeletedFilter].
+type DeletableParams struct {
+	Deleted DeletedFilter
+
+	pagination.Pagination
+}
+
+func (p DeletableParams) DeletedFilter() DeletedFilter {
+	return p.Deleted
+}
+
+func (DeletableParams) GormFilter(db *gorm.DB) *gorm.DB {
+	return db
+}
+
+func (DeletableParams) MemoryFilter(in []DeletableModel) []DeletableModel {
+	return in
+}
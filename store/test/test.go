@@ -2,12 +2,20 @@ package store_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"math/rand"
 	"pckilgore/app/pointers"
 	. "pckilgore/app/store"
+	"pckilgore/app/store/pagination"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,6 +36,56 @@ func (c *counter) Next() int {
 
 var count = &counter{count: 1}
 
+// withFields returns to, with the named fields copied from from.
+func withFields[D any](to D, from D, fields []string) D {
+	dst := reflect.ValueOf(&to).Elem()
+	src := reflect.ValueOf(from)
+	for _, field := range fields {
+		dst.FieldByName(field).Set(src.FieldByName(field))
+	}
+
+	return to
+}
+
+func requireUnique[D Storable](t *testing.T, items []D) {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		require.Falsef(t, seen[item.GetID()], "id=%s should only be listed once", item.GetID())
+		seen[item.GetID()] = true
+	}
+}
+
+// compareSorted orders two models by sort, then by ID.
+func compareSorted[D Storable](a D, b D, sort []Sort) int {
+	for _, s := range sort {
+		c := compareField(
+			reflect.ValueOf(a).FieldByName(s.Field).Interface(),
+			reflect.ValueOf(b).FieldByName(s.Field).Interface(),
+		)
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return strings.Compare(a.GetID(), b.GetID())
+}
+
+func compareField(a any, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case int:
+		return a - b.(int)
+	}
+
+	panic("unsupported sort field type in test")
+}
+
 func CreateStoreTest[D Storable, P Parameterized](
 	t *testing.T,
 	s Store[D, P],
@@ -35,8 +93,14 @@ func CreateStoreTest[D Storable, P Parameterized](
 	modelBuilder func(nonce int) D,
 	// Validate that no deserialization errors occured.
 	modelValidator func(t *testing.T, model D),
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
 	// Generate search parameters.
-	paginationBuild func(limit int, after *Cursor, before *Cursor) P,
+	paginationBuild func(p pagination.Params) P,
+	// Names of fields of D that can be sorted by. The suite overwrites them to
+	// create ties.
+	sortable []string,
 	// Generate filter.
 	filterBuild func(generatedData []D) P,
 	// Validate results against params generated by filterBuild.
@@ -54,7 +118,7 @@ func CreateStoreTest[D Storable, P Parameterized](
 
 		c, err = s.Create(ctx, model)
 		require.Nil(t, c, "should not create duplicate models")
-		require.NotNil(t, err, "should error when attempting to create duplicate")
+		require.ErrorIs(t, err, ErrAlreadyExists, "should error when attempting to create duplicate")
 	})
 
 	t.Run("Retrieve", func(t *testing.T) {
@@ -78,6 +142,65 @@ func CreateStoreTest[D Storable, P Parameterized](
 		modelValidator(t, *r)
 	})
 
+	t.Run("Update", func(t *testing.T) {
+		id := ids[rand.Intn(len(ids))]
+		original, found, err := s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		require.True(t, found)
+		mutated, fields := modelMutator(*original)
+		require.NotEmpty(t, fields, "modelMutator should change at least one field")
+
+		patched, found, err := s.Patch(ctx, mutated)
+		require.Nil(t, err, "store.Patch should not error")
+		require.True(t, found)
+		require.Equal(t, *original, *patched, "an empty field mask should change nothing")
+
+		_, _, err = s.Patch(ctx, mutated, "NotAFieldOnAnyModel")
+		require.ErrorIs(t, err, ErrInvalidInput, "store.Patch should error on unknown fields")
+		for _, field := range fields {
+			// Fields are named as in Go, not by their columns.
+			if column := strings.ToLower(field); column != field {
+				_, _, err = s.Patch(ctx, mutated, column)
+				require.ErrorIsf(t, err, ErrInvalidInput, "store.Patch should error on column %q", column)
+			}
+		}
+
+		patched, found, err = s.Patch(ctx, mutated, fields...)
+		require.Nil(t, err, "store.Patch should not error")
+		require.True(t, found)
+		modelValidator(t, *patched)
+		require.Equal(t, mutated, *patched)
+
+		retrieved, _, err := s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		require.Equal(t, mutated, *retrieved, "patch should be persisted")
+
+		updated, found, err := s.Update(ctx, *original)
+		require.Nil(t, err, "store.Update should not error")
+		require.True(t, found)
+		require.Equal(t, *original, *updated)
+
+		retrieved, _, err = s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		require.Equal(t, *original, *retrieved, "update should be persisted")
+
+		missing := modelBuilder(count.Next())
+		updated, found, err = s.Update(ctx, missing)
+		require.Nil(t, err)
+		require.False(t, found, "store.Update should not find a model that was never created")
+		require.Nil(t, updated)
+
+		_, found, err = s.Patch(ctx, missing, fields...)
+		require.Nil(t, err)
+		require.False(t, found, "store.Patch should not find a model that was never created")
+		_, _, err = s.Patch(ctx, missing, "NotAFieldOnAnyModel")
+		require.ErrorIs(t, err, ErrInvalidInput, "store.Patch should check fields before finding the model")
+
+		_, found, err = s.Retrieve(ctx, missing.GetID())
+		require.Nil(t, err)
+		require.False(t, found, "updates should never create models")
+	})
+
 	t.Run("paginationBuild contract", func(t *testing.T) {
 		t.Parallel()
 		for limit := 1; limit < 100; limit++ {
@@ -89,53 +212,94 @@ func CreateStoreTest[D Storable, P Parameterized](
 				after = pointers.Make(NewCursor(ids[rand.Intn(len(ids))]))
 			}
 
-			params := paginationBuild(limit, after, before)
+			var sort []Sort
+			if limit%3 == 0 && len(sortable) > 0 {
+				sort = []Sort{{Field: sortable[0], Desc: limit%2 == 0}}
+			}
+
+			params := paginationBuild(pagination.Params{Limit: limit, After: after, Before: before, Sort: sort})
 			require.Equal(t, limit, params.Limit(), "paginationBuild did not set limit")
 			require.Equal(t, before, params.Before(), "paginationBuild did not set Before")
 			require.Equal(t, after, params.After(), "paginationBuild did not set After")
+			require.Equal(t, sort, params.Sort(), "paginationBuild did not set Sort")
 		}
 	})
 
 	t.Run("List", func(t *testing.T) {
 		t.Run("pagination", func(t *testing.T) {
 			limit := 77
-			params := paginationBuild(limit, nil, nil)
+			params := paginationBuild(pagination.Params{Limit: limit})
 			list, err := s.List(ctx, params)
 			require.Nil(t, err, "store.Lister should not error")
 			require.Equal(t, len(ids), list.Count)
 			require.Equal(t, limit, len(list.Items))
 
-			params = paginationBuild(100, list.After, nil)
+			params = paginationBuild(pagination.Params{Limit: 100, After: list.After})
 			list, err = s.List(ctx, params)
 			require.Nil(t, err, "store.Lister should not error")
 			require.Equal(t, len(ids), list.Count, "still expect same total count")
 			require.Equal(t, len(ids)-limit, len(list.Items), "the next page should only contain this many items")
 
 			// Get first page of ten.
-			params = paginationBuild(10, nil, nil)
+			params = paginationBuild(pagination.Params{Limit: 10})
 			firstPage, err := s.List(ctx, params)
 			require.Nil(t, err, "store.Lister should not error")
 
 			// Get next page of ten.
-			params = paginationBuild(10, firstPage.After, nil)
+			params = paginationBuild(pagination.Params{Limit: 10, After: firstPage.After})
 			secondPage, err := s.List(ctx, params)
 			require.Nil(t, err, "store.Lister should not error")
 
 			// Get first page (again).
-			params = paginationBuild(10, nil, secondPage.Before)
+			params = paginationBuild(pagination.Params{Limit: 10, Before: secondPage.Before})
 			firstPageRedux, err := s.List(ctx, params)
 			require.Nil(t, err, "store.Lister should not error")
 			require.Equal(t, firstPage, firstPageRedux, "first page should be the same")
 		})
 
+		t.Run("counting", func(t *testing.T) {
+			// A page that ends exactly at the end of the list has nothing after.
+			first, err := s.List(ctx, paginationBuild(pagination.Params{Limit: len(ids) - 100}))
+			require.Nil(t, err)
+			rest, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 100, After: first.After}))
+			require.Nil(t, err)
+			require.Len(t, rest.Items, 100)
+			require.Nil(t, rest.After, "there should be no page after the last")
+
+			for _, mode := range []CountMode{CountNone, CountEstimate} {
+				var listed []D
+				var after *Cursor
+				for {
+					page, err := s.List(ctx, paginationBuild(pagination.Params{
+						Limit: 50,
+						After: after,
+						Count: pointers.Make(mode),
+					}))
+					require.Nil(t, err, "store.Lister should not error")
+					if mode == CountNone {
+						require.Equal(t, -1, page.Count, "store.Lister should not count")
+					} else {
+						require.GreaterOrEqual(t, page.Count, 0, "store.Lister should estimate a count")
+					}
+					listed = append(listed, page.Items...)
+					if page.After == nil {
+						break
+					}
+					after = page.After
+				}
+				require.Len(t, listed, len(ids), "paging should not depend on counting")
+				requireUnique(t, listed)
+			}
+		})
+
 		t.Run("invalid pagination", func(t *testing.T) {
-			params := paginationBuild(10, &Cursor{}, &Cursor{})
+			params := paginationBuild(pagination.Params{Limit: 10, After: &Cursor{}, Before: &Cursor{}})
 			_, err := s.List(ctx, params)
-			require.NotNil(t, err, "store.Lister should err with both before and after cursors")
+			require.ErrorIs(t, err, ErrInvalidPagination, "store.Lister should err with both before and after cursors")
 		})
 
 		t.Run("filtering", func(t *testing.T) {
-			params := paginationBuild(50, nil, nil)
+			params := paginationBuild(pagination.Params{Limit: 50})
 			list, err := s.List(ctx, params)
 			require.Nil(t, err)
 			params = filterBuild(list.Items)
@@ -143,6 +307,264 @@ func CreateStoreTest[D Storable, P Parameterized](
 			require.Nil(t, err)
 			filterValidator(t, params, list.Items)
 		})
+
+		t.Run("sorting", func(t *testing.T) {
+			// Give every group of three models the same sort values, so pages
+			// must break ties by ID.
+			for i, id := range ids {
+				if i%3 == 0 || len(sortable) == 0 {
+					continue
+				}
+				source, _, err := s.Retrieve(ctx, ids[i-i%3])
+				require.Nil(t, err)
+				target, _, err := s.Retrieve(ctx, id)
+				require.Nil(t, err)
+				_, found, err := s.Patch(ctx, withFields(*target, *source, sortable), sortable...)
+				require.Nil(t, err)
+				require.True(t, found)
+			}
+
+			var sorts [][]Sort
+			for _, field := range sortable {
+				sorts = append(sorts, []Sort{{Field: field}}, []Sort{{Field: field, Desc: true}})
+			}
+			if len(sortable) > 1 {
+				var all []Sort
+				for i, field := range sortable {
+					all = append(all, Sort{Field: field, Desc: i%2 == 1})
+				}
+				sorts = append(sorts, all)
+			}
+
+			for _, sort := range sorts {
+				// Page forwards to the end.
+				var forward []D
+				var pages []ListResponse[D]
+				var after *Cursor
+				for {
+					page, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 7, After: after, Sort: sort}))
+					require.Nil(t, err, "store.Lister should not error")
+					forward = append(forward, page.Items...)
+					pages = append(pages, page)
+					if page.After == nil {
+						break
+					}
+					after = page.After
+				}
+				require.Len(t, forward, len(ids), "paging forwards should visit every model")
+				requireUnique(t, forward)
+				for i := 1; i < len(forward); i++ {
+					require.LessOrEqualf(
+						t,
+						compareSorted(forward[i-1], forward[i], sort),
+						0,
+						"%v should not sort after %v by %v",
+						forward[i-1], forward[i], sort,
+					)
+				}
+
+				// Then back to the start.
+				backward := pages[len(pages)-1].Items
+				before := pages[len(pages)-1].Before
+				for before != nil {
+					page, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 7, Before: before, Sort: sort}))
+					require.Nil(t, err, "store.Lister should not error")
+					backward = append(append([]D{}, page.Items...), backward...)
+					before = page.Before
+				}
+				require.Equal(t, forward, backward, "paging backwards should visit every model in the same order")
+			}
+
+			_, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 10, Sort: []Sort{{Field: "NotAFieldOnAnyModel"}}}))
+			require.ErrorIs(t, err, ErrInvalidPagination, "store.Lister should err on unknown sort fields")
+		})
+
+		t.Run("connection", func(t *testing.T) {
+			sorts := [][]Sort{nil}
+			for _, field := range sortable {
+				sorts = append(sorts, []Sort{{Field: field, Desc: true}})
+			}
+
+			for _, sort := range sorts {
+				build := func(limit int, after *Cursor, before *Cursor) P {
+					return paginationBuild(pagination.Params{Limit: limit, After: after, Before: before, Sort: sort})
+				}
+
+				// Page forwards with first/after.
+				var forward []Edge[D]
+				args := ConnectionArgs{First: pointers.Make(10)}
+				for {
+					conn, err := ListConnection[D, P](ctx, s, args, build)
+					require.Nil(t, err, "ListConnection should not error")
+					require.Equal(t, len(ids), conn.TotalCount)
+					require.Equal(t, args.After != nil, conn.PageInfo.HasPreviousPage)
+					require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+					require.Equal(t, conn.Edges[len(conn.Edges)-1].Cursor, *conn.PageInfo.EndCursor)
+					forward = append(forward, conn.Edges...)
+					if !conn.PageInfo.HasNextPage {
+						break
+					}
+					args.After = conn.PageInfo.EndCursor
+				}
+				require.Len(t, forward, len(ids), "paging forwards should visit every model")
+
+				// Every edge cursor resumes from its edge.
+				for _, i := range []int{0, rand.Intn(len(forward) - 1), len(forward) - 2} {
+					conn, err := ListConnection[D, P](
+						ctx,
+						s,
+						ConnectionArgs{First: pointers.Make(1), After: &forward[i].Cursor},
+						build,
+					)
+					require.Nil(t, err)
+					require.Len(t, conn.Edges, 1)
+					require.Equal(t, forward[i+1].Node, conn.Edges[0].Node)
+				}
+
+				// Page backwards with last/before from the last edge.
+				backward := forward[len(forward)-1:]
+				args = ConnectionArgs{Last: pointers.Make(10), Before: &forward[len(forward)-1].Cursor}
+				for {
+					conn, err := ListConnection[D, P](ctx, s, args, build)
+					require.Nil(t, err, "ListConnection should not error")
+					require.True(t, conn.PageInfo.HasNextPage)
+					backward = append(append([]Edge[D]{}, conn.Edges...), backward...)
+					if !conn.PageInfo.HasPreviousPage {
+						break
+					}
+					args.Before = conn.PageInfo.StartCursor
+				}
+				require.Equal(t, forward, backward, "paging backwards should visit every model in the same order")
+
+				// Page backwards with last alone from the end.
+				backward = nil
+				args = ConnectionArgs{Last: pointers.Make(10)}
+				for {
+					conn, err := ListConnection[D, P](ctx, s, args, build)
+					require.Nil(t, err, "ListConnection should not error")
+					require.Equal(t, len(ids), conn.TotalCount)
+					require.Equal(t, args.Before != nil, conn.PageInfo.HasNextPage, "only the last page should have nothing after it")
+					backward = append(append([]Edge[D]{}, conn.Edges...), backward...)
+					if !conn.PageInfo.HasPreviousPage {
+						break
+					}
+					args.Before = conn.PageInfo.StartCursor
+				}
+				require.Equal(t, forward, backward, "paging backwards should visit every model in the same order")
+			}
+
+			build := func(limit int, after *Cursor, before *Cursor) P {
+				return paginationBuild(pagination.Params{Limit: limit, After: after, Before: before})
+			}
+			conn, err := ListConnection[D, P](ctx, s, ConnectionArgs{First: pointers.Make(1)}, build)
+			require.Nil(t, err)
+			cursor := conn.PageInfo.EndCursor
+			_, err = ListConnection[D, P](ctx, s, ConnectionArgs{First: pointers.Make(10), Before: cursor}, build)
+			require.ErrorIs(t, err, ErrInvalidPagination, "first should not page backwards")
+			_, err = ListConnection[D, P](ctx, s, ConnectionArgs{Last: pointers.Make(10), After: cursor}, build)
+			require.ErrorIs(t, err, ErrInvalidPagination, "last should not page forwards")
+		})
+
+		t.Run("cursor binding", func(t *testing.T) {
+			var sorts [][]Sort
+			for _, field := range sortable {
+				sorts = append(sorts, []Sort{{Field: field}}, []Sort{{Field: field, Desc: true}})
+			}
+
+			for i, sort := range sorts {
+				page, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 10, Sort: sort}))
+				require.Nil(t, err)
+				require.NotNil(t, page.After)
+
+				// Changing the page size is fine.
+				_, err = s.List(ctx, paginationBuild(pagination.Params{Limit: 20, After: page.After, Sort: sort}))
+				require.Nil(t, err, "cursors should be reusable with a different limit")
+
+				other := sorts[(i+1)%len(sorts)]
+				_, err = s.List(ctx, paginationBuild(pagination.Params{Limit: 10, After: page.After, Sort: other}))
+				require.ErrorIsf(t, err, ErrCursorMismatch, "a cursor sorted by %v should not list by %v", sort, other)
+
+				_, err = s.List(ctx, paginationBuild(pagination.Params{Limit: 10, Before: page.After}))
+				require.ErrorIsf(t, err, ErrCursorMismatch, "a cursor sorted by %v should not list by ID", sort)
+			}
+
+			page, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 10}))
+			require.Nil(t, err)
+			require.NotNil(t, page.After)
+			unbound := NewCursor(page.After.Value())
+			_, err = s.List(ctx, paginationBuild(pagination.Params{Limit: 10, After: &unbound}))
+			require.ErrorIs(t, err, ErrInvalidCursor, "cursors stripped of their binding should not list")
+		})
+
+		t.Run("iterating", func(t *testing.T) {
+			sorts := [][]Sort{nil}
+			for _, field := range sortable {
+				sorts = append(sorts, []Sort{{Field: field, Desc: true}})
+			}
+
+			for _, sort := range sorts {
+				build := func(after *Cursor) P {
+					return paginationBuild(pagination.Params{
+						Limit: 13,
+						After: after,
+						Sort:  sort,
+						Count: pointers.Make(CountNone),
+					})
+				}
+
+				var paged []D
+				err := Iterate[D, P](ctx, s, build, func(page []D) error {
+					require.LessOrEqual(t, len(page), 13)
+					paged = append(paged, page...)
+					return nil
+				})
+				require.Nil(t, err, "Iterate should not error")
+				require.Len(t, paged, len(ids), "Iterate should visit every model")
+				requireUnique(t, paged)
+
+				var each []D
+				err = Each[D, P](ctx, s, build, func(m D) error {
+					each = append(each, m)
+					return nil
+				})
+				require.Nil(t, err, "Each should not error")
+				require.Equal(t, paged, each, "Each should visit every model in the same order as Iterate")
+			}
+
+			build := func(after *Cursor) P {
+				return paginationBuild(pagination.Params{Limit: 13, After: after, Count: pointers.Make(CountNone)})
+			}
+
+			seen := 0
+			err := Each[D, P](ctx, s, build, func(m D) error {
+				seen++
+				if seen == 20 {
+					return ErrStopIteration
+				}
+				return nil
+			})
+			require.Nil(t, err, "stopping early should not be an error")
+			require.Equal(t, 20, seen)
+
+			failure := errors.New("failure")
+			err = Each[D, P](ctx, s, build, func(m D) error {
+				return failure
+			})
+			require.ErrorIs(t, err, failure, "errors should stop iterating")
+
+			cancelled, cancel := context.WithCancel(ctx)
+			defer cancel()
+			seen = 0
+			err = Each[D, P](cancelled, s, build, func(m D) error {
+				seen++
+				if seen == 20 {
+					cancel()
+				}
+				return nil
+			})
+			require.ErrorIs(t, err, context.Canceled, "iterating should stop when the context is done")
+			require.Equal(t, 20, seen)
+		})
 	})
 
 	t.Run("Delete", func(t *testing.T) {
@@ -164,7 +586,7 @@ func CreateStoreTest[D Storable, P Parameterized](
 			require.True(t, deleted)
 		}
 
-		list, err := s.List(ctx, paginationBuild(100, nil, nil))
+		list, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 100}))
 		require.Nil(t, err)
 		require.Len(t, list.Items, 0, "nothing should remain in DB")
 	})
@@ -179,12 +601,20 @@ func CreateTreeStoreTest[D TreeStorable, P Parameterized](
 	modelBuilder func(nonce int, parentID *string) D,
 	// Validate that no deserialization errors occured.
 	modelValidator func(t *testing.T, model D),
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
 	// Generate search parameters.
-	paginationBuild func(limit int, after *Cursor, before *Cursor) P,
+	paginationBuild func(p pagination.Params) P,
+	// Names of fields of D that can be sorted by. The suite overwrites them to
+	// create ties.
+	sortable []string,
 	// Generate filter.
 	filterBuild func(generatedData []D) P,
 	// Validate results against params generated by filterBuild.
 	filterValidator func(t *testing.T, params P, resultSet []D),
+	// Generate a filter matching exactly the given nodes.
+	treeFilterBuild func(nodes []D) P,
 ) {
 	// If it can't do this, we can't test tree stuff.
 	CreateStoreTest[D, P](
@@ -194,7 +624,9 @@ func CreateTreeStoreTest[D TreeStorable, P Parameterized](
 			return modelBuilder(nonce, nil)
 		},
 		modelValidator,
+		modelMutator,
 		paginationBuild,
+		sortable,
 		filterBuild,
 		filterValidator,
 	)
@@ -228,6 +660,12 @@ func CreateTreeStoreTest[D TreeStorable, P Parameterized](
 		require.Nil(t, err)
 		require.Len(t, list.Layers, 2)
 		require.Len(t, list.Flat(), 2)
+
+		_, err = s.ListAncestors(ctx, modelBuilder(count.Next(), nil).GetID())
+		require.ErrorIs(t, err, ErrNotFound, "should error when the root does not exist")
+
+		_, err = s.ListAncestors(ctx, childCID, AncestorOptions{}, AncestorOptions{})
+		require.ErrorIs(t, err, ErrInvalidInput, "should error when passed more than one set of options")
 	})
 
 	t.Run("ListDescendants", func(t *testing.T) {
@@ -242,5 +680,1344 @@ func CreateTreeStoreTest[D TreeStorable, P Parameterized](
 		require.Nil(t, err)
 		require.Len(t, tree.Flat(), 1)
 		require.Subset(t, tree.Flat(), []D{*childA})
+
+		_, err = s.ListDescendants(ctx, modelBuilder(count.Next(), nil).GetID())
+		require.ErrorIs(t, err, ErrNotFound, "should error when the root does not exist")
+
+		_, err = s.ListDescendants(ctx, rootID, DescendantOptions{}, DescendantOptions{})
+		require.ErrorIs(t, err, ErrInvalidInput, "should error when passed more than one set of options")
+	})
+
+	t.Run("ListDescendants bounded", func(t *testing.T) {
+		// top has three children, each with two children of their own.
+		top, err := s.Create(ctx, modelBuilder(count.Next(), nil))
+		require.Nil(t, err)
+		topID := (*top).GetID()
+		var children, grandchildren []string
+		for i := 0; i < 3; i++ {
+			child, err := s.Create(ctx, modelBuilder(count.Next(), pointers.Make(topID)))
+			require.Nil(t, err)
+			children = append(children, (*child).GetID())
+			for j := 0; j < 2; j++ {
+				grandchild, err := s.Create(ctx, modelBuilder(count.Next(), pointers.Make((*child).GetID())))
+				require.Nil(t, err)
+				grandchildren = append(grandchildren, (*grandchild).GetID())
+			}
+		}
+		sort.Strings(children)
+		sort.Strings(grandchildren)
+
+		ids := func(items []D) []string {
+			var ids []string
+			for _, m := range items {
+				ids = append(ids, m.GetID())
+			}
+			return ids
+		}
+
+		tree, err := s.ListDescendants(ctx, topID)
+		require.Nil(t, err)
+		require.Equal(t, 10, tree.Count)
+		require.False(t, tree.Truncated)
+		require.Equal(t, children, ids(tree.Layers[1].Items), "layers should be in ID order")
+		require.Equal(t, grandchildren, ids(tree.Layers[2].Items), "layers should be in ID order")
+
+		tree, err = s.ListDescendants(ctx, topID, DescendantOptions{MaxDepth: 1})
+		require.Nil(t, err)
+		require.Len(t, tree.Layers, 2, "should stop at the max depth")
+		require.Equal(t, 4, tree.Count)
+		require.False(t, tree.Truncated, "max depth does not truncate")
+
+		tree, err = s.ListDescendants(ctx, topID, DescendantOptions{LayerLimit: 2})
+		require.Nil(t, err)
+		require.Len(t, tree.Layers, 3)
+		require.True(t, tree.Truncated)
+		require.Nil(t, tree.Layers[0].Next, "layers within the limit should not continue")
+		require.Equal(t, children[:2], ids(tree.Layers[1].Items))
+		require.Equal(t, grandchildren[:2], ids(tree.Layers[2].Items))
+		require.NotNil(t, tree.Layers[2].Next, "layers cut short should continue")
+
+		// Page through the rest of the bottom layer.
+		listed := ids(tree.Layers[2].Items)
+		next := tree.Layers[2].Next
+		for next != nil {
+			after, err := Parse(next.Token())
+			require.Nil(t, err)
+			page, err := s.ListDescendants(ctx, topID, DescendantOptions{LayerLimit: 2, After: after})
+			require.Nil(t, err)
+			require.Len(t, page.Layers, 1, "continuing should list one layer")
+			require.Equal(t, 2, page.Layers[0].PathLength)
+			listed = append(listed, ids(page.Layers[0].Items)...)
+			next = page.Layers[0].Next
+		}
+		require.Equal(t, grandchildren, listed, "continuing should list the rest of the layer")
+
+		tree, err = s.ListDescendants(ctx, topID, DescendantOptions{MaxNodes: 5})
+		require.Nil(t, err)
+		require.Equal(t, 5, tree.Count)
+		require.True(t, tree.Truncated)
+		require.Equal(t, grandchildren[:1], ids(tree.Layers[2].Items), "should take nodes by path length then ID")
+		require.NotNil(t, tree.Layers[2].Next)
+
+		tree, err = s.ListDescendants(ctx, topID, DescendantOptions{MaxNodes: 4})
+		require.Nil(t, err)
+		require.Len(t, tree.Layers, 2)
+		require.Nil(t, tree.Layers[1].Next)
+		require.True(t, tree.Truncated, "should report left out layers")
+
+		_, err = s.ListDescendants(ctx, topID, DescendantOptions{MaxDepth: -1})
+		require.ErrorIs(t, err, ErrInvalidInput)
+		cursor := NewLayerCursor(topID, 1, children[0])
+		_, err = s.ListDescendants(ctx, children[0], DescendantOptions{After: &cursor})
+		require.ErrorIs(t, err, ErrCursorMismatch, "should not continue layers of another root")
+		plain := NewCursor(children[0])
+		_, err = s.ListDescendants(ctx, topID, DescendantOptions{After: &plain})
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		// top -> a -> b -> c, and top -> d.
+		build := func(parentID *string) D {
+			m, err := s.Create(ctx, modelBuilder(count.Next(), parentID))
+			require.Nil(t, err)
+			return *m
+		}
+		top := build(nil)
+		a := build(pointers.Make(top.GetID()))
+		b := build(pointers.Make(a.GetID()))
+		c := build(pointers.Make(b.GetID()))
+		d := build(pointers.Make(top.GetID()))
+
+		// layers maps the path length of each layer to the IDs in it.
+		layers := func(tree TreeResponse[D]) map[int][]string {
+			layers := make(map[int][]string)
+			for _, layer := range tree.Layers {
+				for _, m := range layer.Items {
+					layers[layer.PathLength] = append(layers[layer.PathLength], m.GetID())
+				}
+			}
+			return layers
+		}
+
+		tree, err := s.ListAncestors(ctx, c.GetID())
+		require.Nil(t, err)
+		require.Equal(t, map[int][]string{
+			0: {c.GetID()}, 1: {b.GetID()}, 2: {a.GetID()}, 3: {top.GetID()},
+		}, layers(tree), "ancestors should be at their distance")
+
+		bcd := treeFilterBuild([]D{b, c, d})
+		tree, err = s.ListDescendants(ctx, top.GetID(), DescendantOptions{Filter: &TreeFilter{Params: bcd}})
+		require.Nil(t, err)
+		require.Equal(t, map[int][]string{
+			0: {top.GetID()}, 1: {d.GetID()}, 2: {b.GetID()}, 3: {c.GetID()},
+		}, layers(tree), "should look through nodes filtered out")
+		require.Equal(t, 4, tree.Count)
+
+		tree, err = s.ListDescendants(ctx, top.GetID(), DescendantOptions{Filter: &TreeFilter{Params: bcd, Prune: true}})
+		require.Nil(t, err)
+		require.Equal(t, map[int][]string{
+			0: {top.GetID()}, 1: {d.GetID()},
+		}, layers(tree), "should prune below nodes filtered out")
+
+		tree, err = s.ListDescendants(ctx, top.GetID(), DescendantOptions{MaxNodes: 2, Filter: &TreeFilter{Params: bcd}})
+		require.Nil(t, err)
+		require.Equal(t, map[int][]string{
+			0: {top.GetID()}, 1: {d.GetID()},
+		}, layers(tree), "limits should count nodes that match")
+		require.True(t, tree.Truncated)
+
+		topA := treeFilterBuild([]D{top, a})
+		tree, err = s.ListAncestors(ctx, c.GetID(), AncestorOptions{Filter: &TreeFilter{Params: topA}})
+		require.Nil(t, err)
+		require.Equal(t, map[int][]string{
+			0: {c.GetID()}, 2: {a.GetID()}, 3: {top.GetID()},
+		}, layers(tree), "should look through ancestors filtered out")
+
+		tree, err = s.ListAncestors(ctx, c.GetID(), AncestorOptions{Filter: &TreeFilter{Params: topA, Prune: true}})
+		require.Nil(t, err)
+		require.Equal(t, map[int][]string{
+			0: {c.GetID()},
+		}, layers(tree), "should stop at ancestors filtered out")
+
+		wrong := &TreeFilter{Params: pagination.MustNew(pagination.Params{})}
+		_, err = s.ListAncestors(ctx, c.GetID(), AncestorOptions{Filter: wrong})
+		require.ErrorIs(t, err, ErrInvalidInput, "should not filter by other params")
+		_, err = s.ListDescendants(ctx, top.GetID(), DescendantOptions{Filter: wrong})
+		require.ErrorIs(t, err, ErrInvalidInput, "should not filter by other params")
+
+		// Another model's params would filter the wrong records.
+		foreign := &TreeFilter{Params: VersionedParams{Pagination: pagination.MustNew(pagination.Params{})}}
+		_, err = s.ListAncestors(ctx, c.GetID(), AncestorOptions{Filter: foreign})
+		require.ErrorIs(t, err, ErrInvalidInput, "should not filter by another model's params")
+		_, err = s.ListDescendants(ctx, top.GetID(), DescendantOptions{Filter: foreign})
+		require.ErrorIs(t, err, ErrInvalidInput, "should not filter by another model's params")
+	})
+
+	t.Run("family", func(t *testing.T) {
+		// top -> [a -> [a1 -> a11, a2], b, c], and another root.
+		build := func(parent *D) D {
+			var parentID *string
+			if parent != nil {
+				parentID = pointers.Make((*parent).GetID())
+			}
+			m, err := s.Create(ctx, modelBuilder(count.Next(), parentID))
+			require.Nil(t, err)
+			return *m
+		}
+		top := build(nil)
+		a, b, c := build(&top), build(&top), build(&top)
+		a1, a2 := build(&a), build(&a)
+		a11 := build(&a1)
+		other := build(nil)
+
+		ids := func(items []D) []string {
+			ids := []string{}
+			for _, m := range items {
+				ids = append(ids, m.GetID())
+			}
+			return ids
+		}
+		sorted := func(items ...D) []string {
+			sorted := ids(items)
+			sort.Strings(sorted)
+			return sorted
+		}
+		all := paginationBuild(pagination.Params{Limit: 100})
+
+		children, err := s.ListChildren(ctx, top.GetID(), all)
+		require.Nil(t, err)
+		require.Equal(t, sorted(a, b, c), ids(children.Items))
+
+		// Page through the children one at a time.
+		var paged []string
+		var after *Cursor
+		for {
+			page, err := s.ListChildren(ctx, top.GetID(), paginationBuild(pagination.Params{Limit: 2, After: after}))
+			require.Nil(t, err)
+			paged = append(paged, ids(page.Items)...)
+			if page.After == nil {
+				break
+			}
+			after = page.After
+		}
+		require.Equal(t, sorted(a, b, c), paged, "children should paginate")
+
+		children, err = s.ListChildren(ctx, a11.GetID(), all)
+		require.Nil(t, err)
+		require.Empty(t, children.Items)
+
+		siblings, err := s.ListSiblings(ctx, a.GetID(), all)
+		require.Nil(t, err)
+		require.Equal(t, sorted(b, c), ids(siblings.Items), "siblings should not include the node")
+		siblings, err = s.ListSiblings(ctx, top.GetID(), treeFilterBuild([]D{top, a, other}))
+		require.Nil(t, err)
+		require.Equal(t, sorted(other), ids(siblings.Items), "siblings of roots should be roots")
+
+		roots, err := s.ListRoots(ctx, treeFilterBuild([]D{top, a, a11, other}))
+		require.Nil(t, err)
+		require.Equal(t, sorted(top, other), ids(roots.Items))
+
+		leaves, err := s.ListLeaves(ctx, top.GetID(), all)
+		require.Nil(t, err)
+		require.Equal(t, sorted(a11, a2, b, c), ids(leaves.Items))
+		leaves, err = s.ListLeaves(ctx, top.GetID(), treeFilterBuild([]D{a, a2, a11}))
+		require.Nil(t, err)
+		require.Equal(t, sorted(a11, a2), ids(leaves.Items), "leaves should be filtered by params")
+		leaves, err = s.ListLeaves(ctx, a11.GetID(), all)
+		require.Nil(t, err)
+		require.Empty(t, leaves.Items, "leaves should only be under the node")
+
+		missing := modelBuilder(count.Next(), nil).GetID()
+		_, err = s.ListChildren(ctx, missing, all)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = s.ListSiblings(ctx, missing, all)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = s.ListLeaves(ctx, missing, all)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("PathBetween", func(t *testing.T) {
+		// top -> [a -> a1 -> a11, b], and another root.
+		build := func(parent *D) D {
+			var parentID *string
+			if parent != nil {
+				parentID = pointers.Make((*parent).GetID())
+			}
+			m, err := s.Create(ctx, modelBuilder(count.Next(), parentID))
+			require.Nil(t, err)
+			return *m
+		}
+		top := build(nil)
+		a, b := build(&top), build(&top)
+		a1 := build(&a)
+		a11 := build(&a1)
+		other := build(nil)
+
+		type step struct {
+			PathLength int
+			ID         string
+		}
+		steps := func(path TreePath[D]) []step {
+			var steps []step
+			for _, layer := range path.Layers {
+				require.Len(t, layer.Items, 1)
+				steps = append(steps, step{layer.PathLength, layer.Items[0].GetID()})
+			}
+			return steps
+		}
+
+		path, found, err := s.PathBetween(ctx, a11.GetID(), b.GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, []step{
+			{0, a11.GetID()},
+			{1, a1.GetID()},
+			{2, a.GetID()},
+			{3, top.GetID()},
+			{4, b.GetID()},
+		}, steps(path), "should go up from a, then down to b")
+		require.Equal(t, 3, path.Turn)
+
+		lca, found, err := s.LowestCommonAncestor(ctx, a11.GetID(), b.GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, top.GetID(), lca.Node.GetID())
+		require.Equal(t, 3, lca.FromA)
+		require.Equal(t, 1, lca.FromB)
+
+		lca, found, err = s.LowestCommonAncestor(ctx, a1.GetID(), b.GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, top.GetID(), lca.Node.GetID(), "should find the lowest, not the first, ancestor")
+
+		path, found, err = s.PathBetween(ctx, a.GetID(), a11.GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, []step{{0, a.GetID()}, {1, a1.GetID()}, {2, a11.GetID()}}, steps(path))
+		require.Equal(t, 0, path.Turn, "a node should be the ancestor of its descendants")
+		lca = path.CommonAncestor()
+		require.Equal(t, a.GetID(), lca.Node.GetID())
+		require.Equal(t, 0, lca.FromA)
+		require.Equal(t, 2, lca.FromB)
+
+		path, found, err = s.PathBetween(ctx, a1.GetID(), a1.GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, []step{{0, a1.GetID()}}, steps(path), "a node should be its own path")
+
+		_, found, err = s.PathBetween(ctx, a.GetID(), other.GetID())
+		require.Nil(t, err)
+		require.False(t, found, "nodes in different trees should not be related")
+		_, found, err = s.LowestCommonAncestor(ctx, other.GetID(), a11.GetID())
+		require.Nil(t, err)
+		require.False(t, found, "nodes in different trees should not be related")
+
+		missing := modelBuilder(count.Next(), nil).GetID()
+		_, _, err = s.PathBetween(ctx, a.GetID(), missing)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, _, err = s.LowestCommonAncestor(ctx, missing, a.GetID())
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		// from -> moving -> below, and to, moved between them.
+		from, err := s.Create(ctx, modelBuilder(count.Next(), nil))
+		require.Nil(t, err)
+		fromID := (*from).GetID()
+		moving, err := s.Create(ctx, modelBuilder(count.Next(), pointers.Make(fromID)))
+		require.Nil(t, err)
+		movingID := (*moving).GetID()
+		below, err := s.Create(ctx, modelBuilder(count.Next(), pointers.Make(movingID)))
+		require.Nil(t, err)
+		belowID := (*below).GetID()
+		to, err := s.Create(ctx, modelBuilder(count.Next(), nil))
+		require.Nil(t, err)
+		toID := (*to).GetID()
+
+		ids := func(tree TreeResponse[D]) []string {
+			var ids []string
+			for _, m := range tree.Flat() {
+				ids = append(ids, m.GetID())
+			}
+			return ids
+		}
+		parentOf := func(id string) *string {
+			m, found, err := s.Retrieve(ctx, id)
+			require.Nil(t, err)
+			require.True(t, found)
+			return (*m).GetParentID()
+		}
+
+		moved, found, err := s.Move(ctx, movingID, pointers.Make(toID))
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, pointers.Make(toID), (*moved).GetParentID(), "should return the moved node")
+		require.Equal(t, pointers.Make(toID), parentOf(movingID))
+
+		tree, err := s.ListDescendants(ctx, toID)
+		require.Nil(t, err)
+		require.Equal(t, []string{toID, movingID, belowID}, ids(tree), "should move the whole subtree")
+		tree, err = s.ListDescendants(ctx, fromID)
+		require.Nil(t, err)
+		require.Equal(t, []string{fromID}, ids(tree), "should leave the old parent")
+
+		moved, found, err = s.Move(ctx, movingID, nil)
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Nil(t, (*moved).GetParentID(), "should move to the root")
+		require.Nil(t, parentOf(movingID))
+
+		_, _, err = s.Move(ctx, movingID, pointers.Make(movingID))
+		require.ErrorIs(t, err, ErrCycle, "should not move a node under itself")
+		_, _, err = s.Move(ctx, movingID, pointers.Make(belowID))
+		require.ErrorIs(t, err, ErrCycle, "should not move a node under its descendants")
+		require.Nil(t, parentOf(movingID), "should not move on error")
+		require.Equal(t, pointers.Make(movingID), parentOf(belowID))
+
+		// Moving within its own subtree is fine for a descendant.
+		_, _, err = s.Move(ctx, belowID, pointers.Make(fromID))
+		require.Nil(t, err)
+		_, _, err = s.Move(ctx, movingID, pointers.Make(belowID))
+		require.Nil(t, err, "should move under former descendants")
+		tree, err = s.ListAncestors(ctx, movingID)
+		require.Nil(t, err)
+		require.ElementsMatch(t, []string{movingID, belowID, fromID}, ids(tree))
+
+		missing := modelBuilder(count.Next(), nil).GetID()
+		_, found, err = s.Move(ctx, missing, pointers.Make(toID))
+		require.Nil(t, err)
+		require.False(t, found, "should not find missing nodes")
+		_, _, err = s.Move(ctx, movingID, pointers.Make(missing))
+		require.ErrorIs(t, err, ErrNotFound, "should error when the parent does not exist")
+	})
+}
+
+func CreateTransactorTest[D Storable, P Parameterized](
+	t *testing.T,
+	tx Transactor,
+	// A store whose operations join transactions run by tx.
+	s Store[D, P],
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
+) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	existing, err := s.Create(ctx, modelBuilder(count.Next()))
+	require.Nil(t, err)
+	toDelete, err := s.Create(ctx, modelBuilder(count.Next()))
+	require.Nil(t, err)
+	ids := []string{(*existing).GetID(), (*toDelete).GetID()}
+
+	t.Run("rollback", func(t *testing.T) {
+		created := modelBuilder(count.Next())
+		mutated, _ := modelMutator(*existing)
+
+		err := tx.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := s.Create(ctx, created)
+			require.Nil(t, err)
+
+			_, found, err := s.Update(ctx, mutated)
+			require.Nil(t, err)
+			require.True(t, found)
+
+			deleted, err := s.Delete(ctx, (*toDelete).GetID())
+			require.Nil(t, err)
+			require.True(t, deleted)
+
+			_, found, err = s.Retrieve(ctx, created.GetID())
+			require.Nil(t, err)
+			require.True(t, found, "reads in a transaction should see its writes")
+
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback, "RunInTx should return the callback's error")
+
+		_, found, err := s.Retrieve(ctx, created.GetID())
+		require.Nil(t, err)
+		require.False(t, found, "create should be rolled back")
+
+		retrieved, found, err := s.Retrieve(ctx, (*existing).GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, *existing, *retrieved, "update should be rolled back")
+
+		_, found, err = s.Retrieve(ctx, (*toDelete).GetID())
+		require.Nil(t, err)
+		require.True(t, found, "delete should be rolled back")
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		created := modelBuilder(count.Next())
+		err := tx.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := s.Create(ctx, created)
+			return err
+		})
+		require.Nil(t, err)
+		ids = append(ids, created.GetID())
+
+		_, found, err := s.Retrieve(ctx, created.GetID())
+		require.Nil(t, err)
+		require.True(t, found, "create should be committed")
+	})
+
+	t.Run("nested rollback", func(t *testing.T) {
+		outer := modelBuilder(count.Next())
+		inner := modelBuilder(count.Next())
+		err := tx.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := s.Create(ctx, outer)
+			require.Nil(t, err)
+
+			err = tx.RunInTx(ctx, func(ctx context.Context) error {
+				_, err := s.Create(ctx, inner)
+				require.Nil(t, err)
+				return errRollback
+			})
+			require.ErrorIs(t, err, errRollback)
+
+			return nil
+		})
+		require.Nil(t, err)
+		ids = append(ids, outer.GetID())
+
+		_, found, err := s.Retrieve(ctx, outer.GetID())
+		require.Nil(t, err)
+		require.True(t, found, "outer transaction should be committed")
+
+		_, found, err = s.Retrieve(ctx, inner.GetID())
+		require.Nil(t, err)
+		require.False(t, found, "inner transaction should be rolled back")
+	})
+
+	for _, id := range ids {
+		deleted, err := s.Delete(ctx, id)
+		require.Nil(t, err)
+		require.True(t, deleted)
+	}
+}
+
+func CreateBatchTest[D Storable, P Parameterized](
+	t *testing.T,
+	s interface {
+		Store[D, P]
+		BatchCreator[D]
+		BatchDeleter[D]
+	},
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+) {
+	ctx := context.Background()
+
+	requireFound := func(t *testing.T, ms []D, want bool, msg string) {
+		for _, m := range ms {
+			_, found, err := s.Retrieve(ctx, m.GetID())
+			require.Nil(t, err)
+			require.Equalf(t, want, found, "id=%s: %s", m.GetID(), msg)
+		}
+	}
+
+	// Enough models to need more than one statement.
+	var ms []D
+	for i := 0; i < 1234; i++ {
+		ms = append(ms, modelBuilder(count.Next()))
+	}
+
+	t.Run("CreateMany", func(t *testing.T) {
+		created, err := s.CreateMany(ctx, ms)
+		require.Nil(t, err, "store.BatchCreator should not error")
+		require.Len(t, created, len(ms))
+		for i := range ms {
+			require.Equal(t, ms[i].GetID(), created[i].GetID(), "created models should be in the order given")
+		}
+		requireFound(t, ms, true, "every model should be created")
+
+		created, err = s.CreateMany(ctx, nil)
+		require.Nil(t, err, "creating nothing should not error")
+		require.Len(t, created, 0)
+	})
+
+	t.Run("CreateMany conflicts", func(t *testing.T) {
+		fresh := []D{modelBuilder(count.Next()), modelBuilder(count.Next())}
+
+		_, err := s.CreateMany(ctx, append(append([]D{}, fresh...), ms[len(ms)/2]))
+		require.ErrorIs(t, err, ErrAlreadyExists, "creating an existing model should conflict")
+		requireFound(t, fresh, false, "no model should be created when one conflicts")
+
+		_, err = s.CreateMany(ctx, append(append([]D{}, fresh...), fresh[0]))
+		require.ErrorIs(t, err, ErrAlreadyExists, "creating a model twice should conflict")
+		requireFound(t, fresh, false, "no model should be created when two conflict")
+	})
+
+	t.Run("DeleteMany", func(t *testing.T) {
+		var ids []string
+		for _, m := range ms {
+			ids = append(ids, m.GetID())
+		}
+		missing := modelBuilder(count.Next()).GetID()
+
+		deleted, err := s.DeleteMany(ctx, append(ids, missing))
+		require.Nil(t, err, "store.BatchDeleter should not error on missing models")
+		require.Equal(t, len(ms), deleted, "only existing models should be counted")
+		requireFound(t, ms, false, "every model should be deleted")
+
+		deleted, err = s.DeleteMany(ctx, ids)
+		require.Nil(t, err)
+		require.Equal(t, 0, deleted, "deleting twice should delete nothing")
+	})
+}
+
+func CreateUpsertTest[D Storable, P Parameterized](
+	t *testing.T,
+	s interface {
+		Store[D, P]
+		Upserter[D]
+	},
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
+) {
+	ctx := context.Background()
+
+	requireStored := func(t *testing.T, want D, msg string) {
+		retrieved, found, err := s.Retrieve(ctx, want.GetID())
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, want, *retrieved, msg)
+	}
+
+	strategies := []OnConflict{
+		{Strategy: ConflictDoNothing},
+		{Strategy: ConflictOverwrite},
+		{Strategy: ConflictOverwriteFields, Fields: []string{}},
+	}
+
+	var ids []string
+	t.Run("insert", func(t *testing.T) {
+		for _, on := range strategies {
+			m := modelBuilder(count.Next())
+			upserted, outcome, err := s.Upsert(ctx, m, on)
+			require.Nil(t, err, "store.Upserter should not error")
+			require.Equalf(t, UpsertInserted, outcome, "a new model should be inserted under %v", on)
+			require.Equal(t, m, *upserted)
+			requireStored(t, m, "the model should be inserted")
+			ids = append(ids, m.GetID())
+		}
+	})
+
+	t.Run("do nothing", func(t *testing.T) {
+		existing, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		ids = append(ids, (*existing).GetID())
+		mutated, fields := modelMutator(*existing)
+
+		for _, on := range []OnConflict{
+			{Strategy: ConflictDoNothing},
+			{Strategy: ConflictOverwriteFields},
+		} {
+			upserted, outcome, err := s.Upsert(ctx, mutated, on)
+			require.Nil(t, err, "store.Upserter should not error")
+			require.Equalf(t, UpsertSkipped, outcome, "a conflict should be skipped under %v", on)
+			require.Equal(t, *existing, *upserted, "the existing model should be returned")
+			requireStored(t, *existing, "the existing model should be unchanged")
+		}
+
+		_, _, err = s.Upsert(ctx, mutated, OnConflict{Strategy: ConflictOverwriteFields, Fields: append(fields, "NotAFieldOnAnyModel")})
+		require.ErrorIs(t, err, ErrInvalidInput, "store.Upserter should err on unknown fields")
+		requireStored(t, *existing, "nothing should be written when a field is unknown")
+
+		_, _, err = s.Upsert(ctx, mutated, OnConflict{Strategy: ConflictStrategy(-1)})
+		require.ErrorIs(t, err, ErrInvalidInput, "store.Upserter should err on unknown strategies")
+		requireStored(t, *existing, "nothing should be written when the strategy is unknown")
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		existing, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		ids = append(ids, (*existing).GetID())
+		mutated, _ := modelMutator(*existing)
+
+		upserted, outcome, err := s.Upsert(ctx, mutated, OnConflict{Strategy: ConflictOverwrite})
+		require.Nil(t, err, "store.Upserter should not error")
+		require.Equal(t, UpsertUpdated, outcome)
+		require.Equal(t, mutated, *upserted)
+		requireStored(t, mutated, "the model should be overwritten")
+
+		again, fields := modelMutator(mutated)
+		upserted, outcome, err = s.Upsert(ctx, again, OnConflict{Strategy: ConflictOverwriteFields, Fields: fields})
+		require.Nil(t, err, "store.Upserter should not error")
+		require.Equal(t, UpsertUpdated, outcome)
+		require.Equal(t, again, *upserted)
+		requireStored(t, again, "only the named fields should be overwritten")
+	})
+
+	for _, id := range ids {
+		deleted, err := s.Delete(ctx, id)
+		require.Nil(t, err)
+		require.True(t, deleted)
+	}
+}
+
+func CreateVersionedTest[D interface {
+	Storable
+	Versioned[D]
+}, P Parameterized](
+	t *testing.T,
+	s interface {
+		Store[D, P]
+		Upserter[D]
+		VersionedDeleter
+	},
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
+) {
+	ctx := context.Background()
+
+	requireConflict := func(t *testing.T, err error, version int64, current int64) {
+		require.ErrorIs(t, err, ErrVersionConflict)
+		var conflict *VersionConflictError
+		require.ErrorAs(t, err, &conflict)
+		require.Equal(t, version, conflict.Version, "the conflict should report the version written")
+		require.Equal(t, current, conflict.Current, "the conflict should report the version stored")
+	}
+
+	requireVersion := func(t *testing.T, id string, version int64) D {
+		retrieved, found, err := s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		require.True(t, found)
+		require.Equal(t, version, (*retrieved).GetVersion())
+		return *retrieved
+	}
+
+	t.Run("create", func(t *testing.T) {
+		created, err := s.Create(ctx, modelBuilder(count.Next()).WithVersion(42))
+		require.Nil(t, err)
+		require.Equal(t, int64(1), (*created).GetVersion(), "created models should be at version 1")
+		requireVersion(t, (*created).GetID(), 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		created, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+
+		// Two workers read the same version.
+		first, fields := modelMutator(*created)
+		second, _ := modelMutator(first)
+
+		updated, found, err := s.Update(ctx, first)
+		require.Nil(t, err, "the first write should succeed")
+		require.True(t, found)
+		require.Equal(t, first.WithVersion(2), *updated)
+
+		_, _, err = s.Update(ctx, second)
+		requireConflict(t, err, 1, 2)
+		_, _, err = s.Patch(ctx, second, fields...)
+		requireConflict(t, err, 1, 2)
+		require.Equal(t, *updated, requireVersion(t, first.GetID(), 2), "stale writes should not be persisted")
+
+		patched, found, err := s.Patch(ctx, second.WithVersion(2), fields...)
+		require.Nil(t, err, "writes at the current version should succeed")
+		require.True(t, found)
+		require.Equal(t, second.WithVersion(3), *patched)
+		requireVersion(t, first.GetID(), 3)
+
+		patched, found, err = s.Patch(ctx, second)
+		require.Nil(t, err, "an empty field mask changes nothing, so cannot conflict")
+		require.True(t, found)
+		require.Equal(t, int64(3), (*patched).GetVersion())
+
+		_, found, err = s.Update(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		require.False(t, found, "missing models should not conflict")
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		m := modelBuilder(count.Next())
+		upserted, _, err := s.Upsert(ctx, m.WithVersion(42), OnConflict{Strategy: ConflictOverwrite})
+		require.Nil(t, err)
+		require.Equal(t, int64(1), (*upserted).GetVersion(), "inserted models should be at version 1")
+
+		mutated, fields := modelMutator(*upserted)
+		for i, on := range []OnConflict{
+			{Strategy: ConflictOverwrite},
+			{Strategy: ConflictOverwriteFields, Fields: fields},
+		} {
+			upserted, outcome, err := s.Upsert(ctx, mutated.WithVersion(42), on)
+			require.Nil(t, err, "upserts should not conflict")
+			require.Equal(t, UpsertUpdated, outcome)
+			require.Equal(t, int64(i+2), (*upserted).GetVersion(), "upserts should advance the stored version")
+		}
+
+		upserted, _, err = s.Upsert(ctx, mutated, OnConflict{Strategy: ConflictDoNothing})
+		require.Nil(t, err)
+		require.Equal(t, int64(3), (*upserted).GetVersion(), "skipped upserts should not advance the version")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		created, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		mutated, _ := modelMutator(*created)
+		_, _, err = s.Update(ctx, mutated)
+		require.Nil(t, err)
+
+		_, err = s.DeleteVersion(ctx, (*created).GetID(), 1)
+		requireConflict(t, err, 1, 2)
+		requireVersion(t, (*created).GetID(), 2)
+
+		deleted, err := s.DeleteVersion(ctx, (*created).GetID(), 2)
+		require.Nil(t, err)
+		require.True(t, deleted)
+
+		deleted, err = s.DeleteVersion(ctx, (*created).GetID(), 2)
+		require.Nil(t, err, "missing models should not conflict")
+		require.False(t, deleted)
+	})
+}
+
+func CreateSoftDeleteTest[D interface {
+	Storable
+	SoftDeletable[D]
+}, P Parameterized](
+	t *testing.T,
+	s interface {
+		Store[D, P]
+		BatchDeleter[D]
+		SoftDeleter
+	},
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+	// Generate search parameters that list under a deleted filter.
+	paginationBuild func(p pagination.Params, deleted DeletedFilter) P,
+) {
+	ctx := context.Background()
+
+	var ms []D
+	for i := 0; i < 5; i++ {
+		m, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		ms = append(ms, *m)
+	}
+
+	// requireListed checks the models listed under a filter, by ID.
+	requireListed := func(t *testing.T, deleted DeletedFilter, want []D) {
+		var wantIDs []string
+		for _, m := range want {
+			wantIDs = append(wantIDs, m.GetID())
+		}
+
+		list, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 100}, deleted))
+		require.Nil(t, err, "store.Lister should not error")
+		require.Equal(t, len(want), list.Count)
+		var listed []string
+		for _, m := range list.Items {
+			listed = append(listed, m.GetID())
+		}
+		require.ElementsMatchf(t, wantIDs, listed, "listing under filter %d", deleted)
+
+		var each []string
+		err = Each[D, P](ctx, s, func(after *Cursor) P {
+			return paginationBuild(pagination.Params{Limit: 2, After: after}, deleted)
+		}, func(m D) error {
+			each = append(each, m.GetID())
+			return nil
+		})
+		require.Nil(t, err)
+		require.ElementsMatchf(t, wantIDs, each, "iterating under filter %d", deleted)
+	}
+
+	// requireDeleted checks a model is retrievable, and whether it is deleted.
+	requireDeleted := func(t *testing.T, m D, deleted bool, version int64) {
+		retrieved, found, err := s.Retrieve(ctx, m.GetID())
+		require.Nil(t, err)
+		require.True(t, found, "deleted models should still be retrievable")
+		require.Equal(t, deleted, (*retrieved).GetDeletedAt() != nil)
+		if at := (*retrieved).GetDeletedAt(); at != nil {
+			require.Equal(t, time.UTC, at.Location(), "every store should mark deletions in UTC")
+			require.WithinDuration(t, time.Now(), *at, time.Minute)
+		}
+		if v, ok := any(*retrieved).(Versioned[D]); ok {
+			require.Equal(t, version, v.GetVersion(), "deleting and restoring should advance the version")
+		}
+	}
+
+	t.Run("Delete", func(t *testing.T) {
+		deleted, err := s.Delete(ctx, ms[0].GetID())
+		require.Nil(t, err)
+		require.True(t, deleted)
+		requireDeleted(t, ms[0], true, 2)
+
+		deleted, err = s.Delete(ctx, ms[0].GetID())
+		require.Nil(t, err)
+		require.False(t, deleted, "deleted models should not be deleted again")
+		requireDeleted(t, ms[0], true, 2)
+
+		count, err := s.DeleteMany(ctx, []string{ms[0].GetID(), ms[1].GetID(), ms[2].GetID()})
+		require.Nil(t, err)
+		require.Equal(t, 2, count, "only live models should be counted")
+		requireDeleted(t, ms[1], true, 2)
+		requireDeleted(t, ms[2], true, 2)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		requireListed(t, ExcludeDeleted, ms[3:])
+		requireListed(t, IncludeDeleted, ms)
+		requireListed(t, OnlyDeleted, ms[:3])
+
+		page, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 1}, ExcludeDeleted))
+		require.Nil(t, err)
+		_, err = s.List(ctx, paginationBuild(pagination.Params{Limit: 1, After: page.After}, IncludeDeleted))
+		require.ErrorIs(t, err, ErrCursorMismatch, "cursors should be bound to the deleted filter")
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		restored, err := s.Restore(ctx, ms[0].GetID())
+		require.Nil(t, err)
+		require.True(t, restored)
+		requireDeleted(t, ms[0], false, 3)
+
+		restored, err = s.Restore(ctx, ms[0].GetID())
+		require.Nil(t, err)
+		require.False(t, restored, "live models should not be restored")
+
+		restored, err = s.Restore(ctx, modelBuilder(count.Next()).GetID())
+		require.Nil(t, err)
+		require.False(t, restored, "missing models should not be restored")
+
+		requireListed(t, ExcludeDeleted, append([]D{ms[0]}, ms[3:]...))
+		requireListed(t, OnlyDeleted, ms[1:3])
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		for _, m := range ms[:4] {
+			purged, err := s.Purge(ctx, m.GetID())
+			require.Nil(t, err)
+			require.True(t, purged, "live and deleted models should be purged")
+
+			_, found, err := s.Retrieve(ctx, m.GetID())
+			require.Nil(t, err)
+			require.False(t, found, "purged models should not be retrievable")
+		}
+
+		purged, err := s.Purge(ctx, ms[0].GetID())
+		require.Nil(t, err)
+		require.False(t, purged)
+
+		requireListed(t, IncludeDeleted, ms[4:])
+	})
+}
+
+func CreatePinTest[D Storable, P Parameterized](
+	t *testing.T,
+	s interface {
+		Store[D, P]
+		Pinner
+	},
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
+	// Generate search parameters, given pagination.
+	paginationBuild func(p pagination.Params) P,
+) {
+	ctx := context.Background()
+
+	var ms []D
+	for i := 0; i < 20; i++ {
+		m, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		ms = append(ms, *m)
+	}
+
+	// scan pages through every model, a few at a time.
+	scan := func(t *testing.T, ctx context.Context) []D {
+		var all []D
+		err := Each[D, P](ctx, s, func(after *Cursor) P {
+			return paginationBuild(pagination.Params{Limit: 10, After: after, Count: pointers.Make(CountNone)})
+		}, func(m D) error {
+			all = append(all, m)
+			return nil
+		})
+		assert.Nil(t, err, "store.Lister should not error")
+		return all
+	}
+
+	t.Run("pinned reads", func(t *testing.T) {
+		pinned, release := s.Pin(ctx)
+		defer release()
+		before := scan(t, pinned)
+
+		created, err := s.Create(ctx, modelBuilder(count.Next()))
+		require.Nil(t, err)
+		mutated, _ := modelMutator(ms[0])
+		_, _, err = s.Update(ctx, mutated)
+		require.Nil(t, err)
+		_, err = s.Delete(ctx, ms[1].GetID())
+		require.Nil(t, err)
+
+		require.Equal(t, before, scan(t, pinned), "pinned reads should not see later writes")
+		_, found, err := s.Retrieve(pinned, (*created).GetID())
+		require.Nil(t, err)
+		require.False(t, found, "pinned reads should not see later creates")
+		old, found, err := s.Retrieve(pinned, ms[1].GetID())
+		require.Nil(t, err)
+		require.True(t, found, "pinned reads should still see later deletes")
+		require.Equal(t, ms[1], *old)
+
+		_, found, err = s.Retrieve(ctx, (*created).GetID())
+		require.Nil(t, err)
+		require.True(t, found, "unpinned reads should see every write")
+
+		release()
+		_, found, err = s.Retrieve(pinned, (*created).GetID())
+		require.Nil(t, err)
+		require.True(t, found, "released pins should see every write")
+	})
+
+	// Run with -race: pinned scans must be consistent while writers run.
+	t.Run("stress", func(t *testing.T) {
+		var writers sync.WaitGroup
+		done := make(chan struct{})
+		for w := 0; w < 4; w++ {
+			writers.Add(1)
+			go func() {
+				defer writers.Done()
+				var mine []D
+				for i := 0; i < 200; i++ {
+					if len(mine) == 0 || rand.Intn(3) > 0 {
+						m, err := s.Create(ctx, modelBuilder(count.Next()))
+						if assert.Nil(t, err) {
+							mine = append(mine, *m)
+						}
+						continue
+					}
+
+					victim := mine[0]
+					mine = mine[1:]
+					if rand.Intn(2) == 0 {
+						mutated, _ := modelMutator(victim)
+						_, _, err := s.Update(ctx, mutated)
+						assert.Nil(t, err)
+					} else {
+						_, err := s.Delete(ctx, victim.GetID())
+						assert.Nil(t, err)
+					}
+				}
+			}()
+		}
+		go func() {
+			writers.Wait()
+			close(done)
+		}()
+
+		var readers sync.WaitGroup
+		for r := 0; r < 4; r++ {
+			readers.Add(1)
+			go func() {
+				defer readers.Done()
+				for {
+					pinned, release := s.Pin(ctx)
+					first := scan(t, pinned)
+					second := scan(t, pinned)
+					release()
+
+					assert.Equal(t, first, second, "scans of one pin should agree")
+					for j := 1; j < len(first); j++ {
+						assert.Less(t, first[j-1].GetID(), first[j].GetID(), "scans should not repeat or skip back")
+					}
+
+					select {
+					case <-done:
+						return
+					default:
+					}
+				}
+			}()
+		}
+
+		readers.Wait()
+	})
+}
+
+// scribble overwrites every string and number reachable from v through
+// pointers, slices, maps and interfaces.
+func scribble(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			scribble(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			scribble(v.Index(i))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			scribbled := reflect.New(v.Type().Elem()).Elem()
+			scribbled.Set(iter.Value())
+			scribble(scribbled)
+			v.SetMapIndex(iter.Key(), scribbled)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				scribble(v.Field(i))
+			}
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(v.String() + " (scribbled)")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.CanSet() {
+			v.SetInt(v.Int() + 1)
+		}
+	}
+}
+
+func CreateIsolationTest[D Storable, P Parameterized](
+	t *testing.T,
+	s Store[D, P],
+	// Build a model. for each call, nonce is guaranteed to be unique. Set any
+	// pointer, slice or map fields, so there is memory that could be shared.
+	modelBuilder func(nonce int) D,
+	// Generate search parameters, given pagination.
+	paginationBuild func(p pagination.Params) P,
+) {
+	ctx := context.Background()
+
+	m := modelBuilder(count.Next())
+	created, err := s.Create(ctx, m)
+	require.Nil(t, err)
+	id := m.GetID()
+
+	// stored encodes the stored model, so it can be compared with no risk of
+	// sharing memory with it.
+	stored := func() string {
+		found, ok, err := s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		require.True(t, ok)
+		encoded, err := json.Marshal(found)
+		require.Nil(t, err)
+		return string(encoded)
+	}
+	want := stored()
+
+	// Scribbling over the top level of a model only changes a copy, so always
+	// scribble over what a pointer to it reaches.
+	t.Run("created", func(t *testing.T) {
+		scribble(reflect.ValueOf(&m))
+		require.Equal(t, want, stored(), "changing a created model should not change the store")
+		scribble(reflect.ValueOf(created))
+		require.Equal(t, want, stored(), "changing the model returned by Create should not change the store")
+	})
+
+	t.Run("retrieved", func(t *testing.T) {
+		found, _, err := s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		scribble(reflect.ValueOf(found))
+		require.Equal(t, want, stored(), "changing a retrieved model should not change the store")
+	})
+
+	t.Run("listed", func(t *testing.T) {
+		list, err := s.List(ctx, paginationBuild(pagination.Params{Limit: 100}))
+		require.Nil(t, err)
+		require.NotEmpty(t, list.Items)
+		scribble(reflect.ValueOf(&list.Items))
+		require.Equal(t, want, stored(), "changing listed models should not change the store")
+	})
+
+	t.Run("updated", func(t *testing.T) {
+		found, _, err := s.Retrieve(ctx, id)
+		require.Nil(t, err)
+		update := *found
+
+		updated, _, err := s.Update(ctx, update)
+		require.Nil(t, err)
+		want = stored()
+		scribble(reflect.ValueOf(&update))
+		require.Equal(t, want, stored(), "changing an updated model should not change the store")
+		scribble(reflect.ValueOf(updated))
+		require.Equal(t, want, stored(), "changing the model returned by Update should not change the store")
+
+		patched, _, err := s.Patch(ctx, *found)
+		require.Nil(t, err)
+		scribble(reflect.ValueOf(patched))
+		require.Equal(t, want, stored(), "changing the model returned by Patch should not change the store")
+	})
+}
+
+func CreateWatchTest[D Storable, P Parameterized](
+	t *testing.T,
+	s interface {
+		Store[D, P]
+		Watcher[D]
+	},
+	// Build a model. for each call, nonce is guaranteed to be unique.
+	modelBuilder func(nonce int) D,
+	// Change a model without changing its ID, returning the changed model and
+	// the names of the fields that changed.
+	modelMutator func(model D) (mutated D, fields []string),
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	a := modelBuilder(count.Next())
+	b := modelBuilder(count.Next())
+	mutated, _ := modelMutator(a)
+	mine := map[string]bool{a.GetID(): true, b.GetID(): true}
+
+	_, err := s.Create(ctx, a)
+	require.Nil(t, err)
+	_, _, err = s.Update(ctx, mutated)
+	require.Nil(t, err)
+	_, err = s.Delete(ctx, a.GetID())
+	require.Nil(t, err)
+	_, err = s.Create(ctx, b)
+	require.Nil(t, err)
+
+	// collect watches after a token until it has seen n changes to the models
+	// created here, which may follow changes made by other tests.
+	collect := func(t *testing.T, after ResumeToken, n int) []Change[D] {
+		var changes []Change[D]
+		err := s.Watch(ctx, after, func(change Change[D]) error {
+			if !mine[change.ID] {
+				return nil
+			}
+			changes = append(changes, change)
+			if len(changes) == n {
+				return ErrStopIteration
+			}
+			return nil
+		})
+		require.Nil(t, err, "store.Watcher should stop without error")
+		return changes
+	}
+
+	var changes []Change[D]
+	t.Run("ordered", func(t *testing.T) {
+		changes = collect(t, "", 4)
+
+		require.Equal(t, ChangeCreate, changes[0].Kind)
+		require.Equal(t, a, *changes[0].Model)
+		require.Equal(t, ChangeUpdate, changes[1].Kind)
+		require.Equal(t, mutated, *changes[1].Model)
+		require.Equal(t, ChangeDelete, changes[2].Kind)
+		require.Equal(t, a.GetID(), changes[2].ID)
+		require.Nil(t, changes[2].Model, "deletes should have no model")
+		require.Equal(t, ChangeCreate, changes[3].Kind)
+		require.Equal(t, b, *changes[3].Model)
+
+		for i := 1; i < len(changes); i++ {
+			require.Less(t, changes[i-1].Seq, changes[i].Seq, "changes should be in sequence")
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		require.Len(t, changes, 4)
+		require.Equal(t, changes[2:], collect(t, changes[1].Token(), 2))
+	})
+
+	t.Run("live", func(t *testing.T) {
+		require.Len(t, changes, 4)
+		c := modelBuilder(count.Next())
+		mine[c.GetID()] = true
+
+		watched := make(chan []Change[D], 1)
+		go func() {
+			var live []Change[D]
+			err := s.Watch(ctx, changes[3].Token(), func(change Change[D]) error {
+				if mine[change.ID] {
+					live = append(live, change)
+					return ErrStopIteration
+				}
+				return nil
+			})
+			assert.Nil(t, err, "store.Watcher should stop without error")
+			watched <- live
+		}()
+
+		_, err := s.Create(ctx, c)
+		require.Nil(t, err)
+
+		live := <-watched
+		require.Len(t, live, 1, "watchers should see changes made while waiting")
+		require.Equal(t, ChangeCreate, live[0].Kind)
+		require.Equal(t, c.GetID(), live[0].ID)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		failing := errors.New("failing")
+		err := s.Watch(ctx, "", func(Change[D]) error {
+			return failing
+		})
+		require.ErrorIs(t, err, failing)
+
+		err = s.Watch(ctx, "not a token", func(Change[D]) error {
+			return nil
+		})
+		require.ErrorIs(t, err, ErrInvalidInput)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		err = s.Watch(cancelled, changes[3].Token(), func(Change[D]) error {
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		mover, ok := any(s).(interface {
+			Move(ctx context.Context, id string, parentID *string) (*D, bool, error)
+		})
+		if !ok {
+			t.Skip("store does not move models")
+		}
+		require.Len(t, changes, 4)
+
+		parent := modelBuilder(count.Next())
+		child := modelBuilder(count.Next())
+		_, err := s.Create(ctx, parent)
+		require.Nil(t, err)
+		_, err = s.Create(ctx, child)
+		require.Nil(t, err)
+		mine[child.GetID()] = true
+
+		parentID := parent.GetID()
+		moved, found, err := mover.Move(ctx, child.GetID(), &parentID)
+		require.Nil(t, err)
+		require.True(t, found)
+
+		var moves []Change[D]
+		err = s.Watch(ctx, changes[3].Token(), func(change Change[D]) error {
+			if change.ID != child.GetID() {
+				return nil
+			}
+			moves = append(moves, change)
+			if len(moves) == 2 {
+				return ErrStopIteration
+			}
+			return nil
+		})
+		require.Nil(t, err, "store.Watcher should stop without error")
+
+		require.Equal(t, ChangeCreate, moves[0].Kind)
+		require.Equal(t, ChangeUpdate, moves[1].Kind, "moves should be recorded as updates")
+		require.NotNil(t, moves[1].Model)
+		require.Equal(t, (*moved).GetID(), (*moves[1].Model).GetID())
+		if tree, ok := any(*moves[1].Model).(Treeable); ok {
+			require.Equal(t, &parentID, tree.GetParentID(), "the change should hold the moved model")
+		}
 	})
 }
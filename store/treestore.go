@@ -2,7 +2,12 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"sort"
+
+	"github.com/pkg/errors"
 )
 
 type Treeable interface {
@@ -22,16 +27,257 @@ type TreeStorable interface {
 }
 
 type AncestorLister[Model TreeStorable] interface {
-	ListAncestors(ctx context.Context, id string) (TreeResponse[Model], error)
+	// ListAncestors lists the path from id, at path length zero, up to its
+	// root, a node per layer. Passing more than one set of options errors
+	// with [ErrInvalidInput].
+	ListAncestors(ctx context.Context, id string, o ...AncestorOptions) (TreeResponse[Model], error)
+}
+
+// AncestorOptions refine what an [AncestorLister] lists.
+type AncestorOptions struct {
+	Filter *TreeFilter
+}
+
+// TreeFilter restricts a tree query to the nodes a store's parameters would
+// list. The node a query starts from is always listed.
+type TreeFilter struct {
+	// Params filter nodes as they do lists, ignoring pagination and sort. They
+	// must be of the store's parameter type, else queries error with
+	// [ErrInvalidInput].
+	Params Parameterized
+
+	// Prune leaves out everything beyond a node that is filtered out, rather
+	// than looking through it for nodes that match.
+	Prune bool
 }
 
 type DescendantLister[Model TreeStorable] interface {
-	ListDescendants(ctx context.Context, id string) (TreeResponse[Model], error)
+	// ListDescendants lists the subtree under id, including id itself, in
+	// layers of nodes in ID order. Options bound how much is listed; passing
+	// more than one set errors with [ErrInvalidInput].
+	ListDescendants(ctx context.Context, id string, o ...DescendantOptions) (TreeResponse[Model], error)
+}
+
+// DescendantOptions bound what a [DescendantLister] lists, for subtrees too
+// large to list whole. Zero values are unbounded.
+type DescendantOptions struct {
+	// MaxDepth is the greatest path length listed.
+	MaxDepth int
+
+	// LayerLimit caps the nodes listed in each layer. Layers cut short have a
+	// cursor to list more of them.
+	LayerLimit int
+
+	// MaxNodes caps the nodes listed across every layer, which are taken by
+	// path length, then ID.
+	MaxNodes int
+
+	// After continues a layer cut short in an earlier listing from the same
+	// root, listing only that layer's nodes after the cursor. The rest of the
+	// options should be as they were.
+	After *Cursor
+
+	// Filter restricts the nodes listed. Limits count only nodes that match.
+	Filter *TreeFilter
+}
+
+// LayerPosition is a node in a layer of descendants, which a cursor from
+// [NewLayerCursor] continues after.
+type LayerPosition struct {
+	PathLength int
+	ID         string
+}
+
+// NewLayerCursor returns a cursor continuing the layer of the descendants of
+// rootID at pathLength after the node with id.
+func NewLayerCursor(rootID string, pathLength int, id string) Cursor {
+	// Encoding an int cannot fail.
+	cursor, _ := NewKeysetCursor(id, pathLength)
+	return cursor.Bind(layerFingerprint(rootID))
+}
+
+func layerFingerprint(rootID string) string {
+	h := sha256.Sum256([]byte("descendants\x00" + rootID))
+	return base64.RawURLEncoding.EncodeToString(h[:16])
+}
+
+// Check validates o for listing the descendants of rootID, returning where its
+// cursor continues, if it has one. Negative bounds error with
+// [ErrInvalidInput], and cursors not from [NewLayerCursor] with
+// [ErrInvalidCursor], or [ErrCursorMismatch] if from another root.
+func (o DescendantOptions) Check(rootID string) (*LayerPosition, error) {
+	if o.MaxDepth < 0 || o.LayerLimit < 0 || o.MaxNodes < 0 {
+		return nil, errors.Wrap(ErrInvalidInput, "descendant bounds must not be negative")
+	}
+	if o.After == nil {
+		return nil, nil
+	}
+
+	if err := o.After.Verify(layerFingerprint(rootID)); err != nil {
+		return nil, err
+	}
+	position := LayerPosition{ID: o.After.Value()}
+	keys := o.After.Keys()
+	if len(keys) != 1 {
+		return nil, errors.Wrap(ErrInvalidCursor, "not a layer cursor")
+	}
+	if err := json.Unmarshal(keys[0], &position.PathLength); err != nil || position.PathLength < 0 {
+		return nil, errors.Wrap(ErrInvalidCursor, "not a layer cursor")
+	}
+
+	return &position, nil
+}
+
+// TrimLayers applies the limits of o to layers of the descendants of rootID,
+// in path length order with the nodes of each in ID order. Stores pass at least
+// one more node than each limit, where there are more, so that layers cut
+// short can be told apart.
+func TrimLayers[Model Storable](rootID string, o DescendantOptions, layers []Layer[Model]) TreeResponse[Model] {
+	var response TreeResponse[Model]
+	for _, layer := range layers {
+		items := layer.Items
+		cut := false
+		if o.LayerLimit > 0 && len(items) > o.LayerLimit {
+			items, cut = items[:o.LayerLimit], true
+		}
+		if o.MaxNodes > 0 && response.Count+len(items) > o.MaxNodes {
+			items, cut = items[:o.MaxNodes-response.Count], true
+		}
+		if cut {
+			response.Truncated = true
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		trimmed := Layer[Model]{PathLength: layer.PathLength, Items: items}
+		if cut {
+			next := NewLayerCursor(rootID, layer.PathLength, items[len(items)-1].GetID())
+			trimmed.Next = &next
+		}
+		response.Layers = append(response.Layers, trimmed)
+		response.Count += len(items)
+	}
+
+	return response
+}
+
+// Mover moves nodes, with their descendants, to a new parent.
+type Mover[Model TreeStorable] interface {
+	// Move makes the node with id a child of parentID, or a root if nil, in a
+	// single atomic write, returning the moved node and whether it was found.
+	// Moving a node under itself or one of its descendants errors with
+	// [ErrCycle], and under a parent that does not exist with [ErrNotFound].
+	// Moves are writes, so advance the version of [Versioned] models.
+	Move(ctx context.Context, id string, parentID *string) (*Model, bool, error)
+}
+
+// Movable tree models can be moved by stores that write whole models, such as
+// the memory store, rather than their parent column alone.
+type Movable[Model any] interface {
+	// WithParentID returns a copy of the model under parentID, or a root if
+	// nil.
+	WithParentID(parentID *string) Model
+}
+
+// PathFinder relates pairs of nodes through their ancestors. Nodes in
+// different trees are not related, so are not found, and nodes that do not
+// exist error with [ErrNotFound].
+type PathFinder[Model TreeStorable] interface {
+	// LowestCommonAncestor returns the deepest node that is an ancestor of
+	// both a and b, which may be either of them.
+	LowestCommonAncestor(ctx context.Context, a, b string) (CommonAncestor[Model], bool, error)
+
+	// PathBetween returns the path from a up to the lowest common ancestor,
+	// then down to b.
+	PathBetween(ctx context.Context, a, b string) (TreePath[Model], bool, error)
+}
+
+// CommonAncestor is the lowest common ancestor of two nodes, a and b.
+type CommonAncestor[Model any] struct {
+	Node Model
+
+	// FromA and FromB are the path lengths up to Node from a and b.
+	FromA int
+	FromB int
+}
+
+// TreePath is the path between two nodes, a and b, in order from a, one node
+// per layer at its distance from a.
+type TreePath[Model any] struct {
+	Layers []Layer[Model]
+
+	// Turn is the position in Layers of the lowest common ancestor, where the
+	// path stops going up and starts going down.
+	Turn int
+}
+
+// CommonAncestor returns the lowest common ancestor the path turns at.
+func (p TreePath[Model]) CommonAncestor() CommonAncestor[Model] {
+	return CommonAncestor[Model]{
+		Node:  p.Layers[p.Turn].Items[0],
+		FromA: p.Turn,
+		FromB: len(p.Layers) - 1 - p.Turn,
+	}
+}
+
+// JoinAncestors returns the path between a and b given their ancestors, as
+// listed by an [AncestorLister] without a filter, or false if they have no
+// common ancestor.
+func JoinAncestors[Model Storable](a, b TreeResponse[Model]) (TreePath[Model], bool) {
+	up := a.Flat()
+	down := b.Flat()
+
+	fromA := make(map[string]int, len(up))
+	for i, m := range up {
+		fromA[m.GetID()] = i
+	}
+
+	for fromB, m := range down {
+		turn, ok := fromA[m.GetID()]
+		if !ok {
+			continue
+		}
+
+		var path TreePath[Model]
+		for i := 0; i <= turn; i++ {
+			path.Layers = append(path.Layers, Layer[Model]{PathLength: i, Items: []Model{up[i]}})
+		}
+		for i := fromB - 1; i >= 0; i-- {
+			path.Layers = append(path.Layers, Layer[Model]{PathLength: len(path.Layers), Items: []Model{down[i]}})
+		}
+		path.Turn = turn
+
+		return path, true
+	}
+
+	return TreePath[Model]{}, false
 }
 
 type Tree[Model TreeStorable] interface {
 	AncestorLister[Model]
 	DescendantLister[Model]
+	Mover[Model]
+	PathFinder[Model]
+}
+
+// TreeLister lists nodes by their place in a tree, paginated, sorted and
+// filtered by params as [Lister] lists are. Listing around a node that does
+// not exist errors with [ErrNotFound].
+type TreeLister[Model TreeStorable, Params Parameterized] interface {
+	// ListChildren lists the nodes directly under id.
+	ListChildren(ctx context.Context, id string, params Params) (ListResponse[Model], error)
+
+	// ListSiblings lists the other nodes under the parent of id, or the other
+	// roots if it is one.
+	ListSiblings(ctx context.Context, id string, params Params) (ListResponse[Model], error)
+
+	// ListRoots lists the nodes without a parent.
+	ListRoots(ctx context.Context, params Params) (ListResponse[Model], error)
+
+	// ListLeaves lists the nodes under id, at any depth, without children of
+	// their own.
+	ListLeaves(ctx context.Context, id string, params Params) (ListResponse[Model], error)
 }
 
 // TreeStore is an advanced store implementation that's capable of querying
@@ -40,6 +286,9 @@ type TreeStore[Model TreeStorable, Params Parameterized] interface {
 	Store[Model, Params]
 	AncestorLister[Model]
 	DescendantLister[Model]
+	Mover[Model]
+	PathFinder[Model]
+	TreeLister[Model, Params]
 }
 
 // A Layer is a set of nodes at the relative path length from the root of the
@@ -47,6 +296,9 @@ type TreeStore[Model TreeStorable, Params Parameterized] interface {
 type Layer[Model any] struct {
 	PathLength int
 	Items      []Model
+
+	// Next continues the layer, if it was cut short by [DescendantOptions].
+	Next *Cursor
 }
 
 type TreeResponse[Model any] struct {
@@ -58,6 +310,10 @@ type TreeResponse[Model any] struct {
 
 	// Count is the total number of items available across all layers
 	Count int
+
+	// Truncated is whether [DescendantOptions] limits left out nodes within
+	// the depth listed.
+	Truncated bool
 }
 
 // Flat returns the complete list of nodes in [TreeResponse] in path-length
@@ -0,0 +1,39 @@
+package store
+
+// ConflictStrategy is how an [Upserter] resolves a record that already exists.
+type ConflictStrategy int
+
+const (
+	// ConflictDoNothing keeps the existing record unchanged.
+	ConflictDoNothing ConflictStrategy = iota
+
+	// ConflictOverwrite replaces the existing record, as [Updater] Update does.
+	ConflictOverwrite
+
+	// ConflictOverwriteFields copies only the named fields onto the existing
+	// record, as [Updater] Patch does.
+	ConflictOverwriteFields
+)
+
+// OnConflict configures how an [Upserter] resolves conflicts.
+type OnConflict struct {
+	Strategy ConflictStrategy
+
+	// Fields are the fields overwritten under ConflictOverwriteFields, named as
+	// on the Go struct, e.g. "Name". With no fields, conflicts are skipped.
+	Fields []string
+}
+
+// UpsertOutcome reports what an [Upserter] did with a record.
+type UpsertOutcome int
+
+const (
+	// UpsertInserted means the record did not exist, and was created.
+	UpsertInserted UpsertOutcome = iota + 1
+
+	// UpsertUpdated means the record existed, and was overwritten.
+	UpsertUpdated
+
+	// UpsertSkipped means the record existed, and was left unchanged.
+	UpsertSkipped
+)
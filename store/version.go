@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Versioned models are protected from lost updates. Stores set their version
+// to 1 when they are created, and increment it on every write. Updates, patches
+// and [VersionedDeleter] deletes are rejected with a [VersionConflictError]
+// unless the model's version matches the stored record's, i.e. unless nothing
+// has written to the record since the model was read. Upserts overwrite
+// regardless, but still increment the version.
+type Versioned[Model any] interface {
+	GetVersion() int64
+
+	// WithVersion returns a copy of the model at version.
+	WithVersion(version int64) Model
+
+	// GetVersionField returns the name of the column holding the version.
+	GetVersionField() string
+}
+
+// VersionedDeleter deletes a record only if it is at the version given, i.e.
+// if nothing has written to it since it was read.
+type VersionedDeleter interface {
+	DeleteVersion(ctx context.Context, id string, version int64) (bool, error)
+}
+
+// VersionConflictError is returned when writing a stale [Versioned] model. It
+// is an [ErrVersionConflict].
+type VersionConflictError struct {
+	ID string
+
+	// Version is the version written, and Current the version stored.
+	Version int64
+	Current int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: record %s is at version %d, not %d", ErrVersionConflict, e.ID, e.Current, e.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// ETag formats a version as an HTTP entity tag, e.g. for an ETag header.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ParseETag parses an entity tag formatted by [ETag], e.g. from an If-Match
+// header, or errors with [ErrInvalidInput].
+func ParseETag(tag string) (int64, error) {
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil || len(tag) == 0 || tag[0] != '"' {
+		return 0, errors.Wrapf(ErrInvalidInput, "invalid entity tag %q", tag)
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		return 0, errors.Wrapf(ErrInvalidInput, "invalid entity tag %q", tag)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,24 @@
+package store_test
+
+import (
+	"testing"
+
+	"pckilgore/app/store"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []int64{1, 2, 1 << 40} {
+		parsed, err := store.ParseETag(store.ETag(version))
+		require.Nil(t, err)
+		require.Equal(t, version, parsed)
+	}
+
+	for _, tag := range []string{"", "1", `"one"`, `"0"`, `W/"1"`, "`1`"} {
+		_, err := store.ParseETag(tag)
+		require.ErrorIsf(t, err, store.ErrInvalidInput, "%s should not parse", tag)
+	}
+}
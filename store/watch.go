@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeKind is the kind of write a [Change] records.
+type ChangeKind int
+
+const (
+	// ChangeCreate is a record that did not exist being written.
+	ChangeCreate ChangeKind = iota + 1
+
+	// ChangeUpdate is an existing record being written. Marking a
+	// [SoftDeletable] record deleted, or restoring it, is an update.
+	ChangeUpdate
+
+	// ChangeDelete is a record being removed.
+	ChangeDelete
+)
+
+// Change is a write to a single record, as streamed by a [Watcher].
+type Change[Model any] struct {
+	// Seq orders the changes of a store: each is greater than the last.
+	Seq int64
+
+	Kind ChangeKind
+	ID   string
+
+	// Model is the record as written, or nil if it was removed.
+	Model *Model
+}
+
+// Token returns a token to resume watching after c.
+func (c Change[Model]) Token() ResumeToken {
+	return NewResumeToken(c.Seq)
+}
+
+// DiffChange returns the change made by writing a record from before to
+// after, either of which is nil if the record did not exist, or false if the
+// write changed nothing.
+func DiffChange[Model any](id string, before *Model, after *Model) (Change[Model], bool) {
+	change := Change[Model]{ID: id, Model: after}
+	switch {
+	case before == nil && after == nil:
+		return change, false
+	case before == nil:
+		change.Kind = ChangeCreate
+	case after == nil:
+		change.Kind = ChangeDelete
+	case reflect.DeepEqual(*before, *after):
+		return change, false
+	default:
+		change.Kind = ChangeUpdate
+	}
+
+	return change, true
+}
+
+const resumePrefix = "resume_"
+
+// ResumeToken is an opaque position in a store's changes, which a [Watcher]
+// resumes after. Subscribers persist the token of the last change they
+// handled to pick up where they left off after restarting. The zero token is
+// before the oldest change kept.
+type ResumeToken string
+
+// NewResumeToken returns the token positioned after the change with seq.
+func NewResumeToken(seq int64) ResumeToken {
+	return ResumeToken(resumePrefix + strconv.FormatInt(seq, 10))
+}
+
+// Seq returns the sequence number of the change the token is positioned after,
+// or errors with [ErrInvalidInput]. The zero token has no sequence number.
+func (t ResumeToken) Seq() (int64, error) {
+	digits, found := strings.CutPrefix(string(t), resumePrefix)
+	if !found {
+		return 0, errors.Wrapf(ErrInvalidInput, "invalid resume token %q", t)
+	}
+
+	seq, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, errors.Wrapf(ErrInvalidInput, "invalid resume token %q", t)
+	}
+
+	return seq, nil
+}
+
+// Watcher streams the changes made to a store, in order.
+type Watcher[Model Storable] interface {
+	// Watch calls fn with every change after the token, in order, waiting for
+	// more until ctx is done or fn errors. Returning [ErrStopIteration] from fn
+	// stops watching without error. Resuming from a token whose changes are no
+	// longer kept errors with [ErrTokenExpired], and from a malformed one with
+	// [ErrInvalidInput].
+	Watch(ctx context.Context, after ResumeToken, fn func(Change[Model]) error) error
+}
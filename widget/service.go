@@ -4,21 +4,33 @@ import (
 	"context"
 	"pckilgore/app/pointers"
 	"pckilgore/app/store"
+	"pckilgore/app/store/pagination"
 
 	"github.com/pkg/errors"
 )
 
-type WidgetStore = store.Store[DatabaseWidget, WidgetParams]
+type WidgetStore interface {
+	store.Store[DatabaseWidget, WidgetParams]
+	store.BatchCreator[DatabaseWidget]
+}
 
 type Service struct {
-	store WidgetStore
+	store  WidgetStore
+	policy pagination.Policy
 }
 
-func NewService(store WidgetStore) Service {
-	return Service{store: store}
+// NewService constructs a widget service that lists widgets under policy.
+func NewService(store WidgetStore, policy pagination.Policy) Service {
+	return Service{store: store, policy: policy}
 }
 
 func (s Service) List(c context.Context, p WidgetParams) (*store.ListResponse[widget], error) {
+	paging, err := p.Pagination.Under(s.policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid pagination for widgets")
+	}
+	p.Pagination = paging
+
 	dbw, err := s.store.List(c, p)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list widget")
@@ -34,18 +46,25 @@ func (s Service) List(c context.Context, p WidgetParams) (*store.ListResponse[wi
 	}
 
 	return &store.ListResponse[widget]{
-		Count:  dbw.Count,
-		Items:  items,
-		After:  dbw.After,
-		Before: dbw.Before,
+		Count:   dbw.Count,
+		Items:   items,
+		Cursors: dbw.Cursors,
+		After:   dbw.After,
+		Before:  dbw.Before,
 	}, nil
 }
 
-func (s Service) Create(c context.Context, t WidgetTemplate) (*widget, error) {
+func newWidget(t WidgetTemplate) widget {
 	var w widget
 	w.Name = pointers.GetWithDefault(t.Name, "Some Widget")
 	w.ID = CreateID()
 
+	return w
+}
+
+func (s Service) Create(c context.Context, t WidgetTemplate) (*widget, error) {
+	w := newWidget(t)
+
 	// Persist
 	res, err := s.store.Create(c, Serialize(w))
 	if err != nil {
@@ -54,3 +73,52 @@ func (s Service) Create(c context.Context, t WidgetTemplate) (*widget, error) {
 
 	return Deserialize(res)
 }
+
+// CreateMany creates a widget for each template, or none of them.
+func (s Service) CreateMany(c context.Context, ts []WidgetTemplate) ([]widget, error) {
+	var dbws []DatabaseWidget
+	for _, t := range ts {
+		dbws = append(dbws, Serialize(newWidget(t)))
+	}
+
+	// Persist
+	res, err := s.store.CreateMany(c, dbws)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to save created widgets")
+	}
+
+	var items []widget
+	for _, i := range res {
+		item, err := Deserialize(&i)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to deserialize widget")
+		}
+		items = append(items, *item)
+	}
+
+	return items, nil
+}
+
+// Update applies a template to an existing widget. Only fields set on the
+// template are changed.
+func (s Service) Update(c context.Context, id ID, t WidgetTemplate) (*widget, error) {
+	if t.ID != nil {
+		return nil, errors.Wrap(store.ErrInvalidInput, "widget IDs cannot be updated")
+	}
+
+	dbw := DatabaseWidget{ID: getDatabaseIDFromID(id)}
+	var fields []string
+	if t.Name != nil {
+		dbw.Name = *t.Name
+		fields = append(fields, "Name")
+	}
+
+	res, found, err := s.store.Patch(c, dbw, fields...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to save updated widget")
+	} else if !found {
+		return nil, errors.Wrapf(store.ErrNotFound, "failed to find widget %s", id)
+	}
+
+	return Deserialize(res)
+}
@@ -105,6 +105,10 @@ func getIDFromDatabaseID(dbID string) ID {
 	return ID(dbID)
 }
 
+func getDatabaseIDFromID(id ID) string {
+	return string(id)
+}
+
 func maybeGetIDFromDatabaseID(dbID *string) *ID {
 	if dbID != nil {
 		return pointers.Make(getIDFromDatabaseID(*dbID))